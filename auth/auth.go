@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"github.com/MicahParks/keyfunc/v3"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/kobble-io/go-admin/common"
 	"github.com/kobble-io/go-admin/utils"
 	"net/http"
 	"strings"
@@ -12,40 +14,51 @@ import (
 
 type KobbleAuth struct {
 	issuer       string
-	projectCache *utils.Cache[projectCache]
+	projectCache *utils.LoadingCache[string]
 	config       Config
 }
 
+// The lifetimes of the cached project ID, and the time allowed to fetch it. They are variables so that tests can shorten them.
+var (
+	projectCacheTtl         = 50 * time.Minute
+	projectCacheStaleTtl    = 10 * time.Minute
+	projectCacheLoadTimeout = 30 * time.Second
+)
+
 func NewKobbleAuth(conf Config) *KobbleAuth {
-	defaultTtl := 50 * time.Minute / time.Second
+	projectCache := conf.ProjectCache
+	if projectCache == nil {
+		projectCache = utils.NewMemoryCache[string](utils.CacheConfig{})
+	}
 	return &KobbleAuth{
 		issuer: "https://kobble.io",
-		projectCache: utils.NewCache[projectCache](utils.CacheConfig{
-			DefaultTtl: &defaultTtl,
+		projectCache: utils.NewLoadingCache(projectCache, utils.LoadingCacheConfig{
+			Ttl:         projectCacheTtl,
+			StaleTtl:    projectCacheStaleTtl,
+			LoadTimeout: projectCacheLoadTimeout,
 		}),
 		config: conf,
 	}
 }
 
 func (auth KobbleAuth) getProjectId() (string, error) {
-	cacheProject := auth.projectCache.Get("default")
-	if cacheProject != nil {
-		return cacheProject.ProjectID, nil
-	}
-
-	var whoami Whoami
-	err := auth.config.Http.GetJson("/auth/whoami", nil, &whoami, http.StatusOK)
-	if err != nil {
-		return "", err
-	}
+	return auth.projectCache.Get(context.Background(), "projectId", func(ctx context.Context) (string, error) {
+		var whoami Whoami
+		err := auth.config.Http.GetJsonWithContext(ctx, "/auth/whoami", nil, &whoami, http.StatusOK)
+		if err != nil {
+			return "", err
+		}
 
-	auth.projectCache.Set("projectId", projectCache{ProjectID: whoami.ProjectId}, nil)
-	return whoami.ProjectId, nil
+		return whoami.ProjectId, nil
+	})
 }
 
 // VerifyAccessToken verify an Access Token generated by your OAuth Application or throw an error.
 // This method will verify the token signature and expiration time.
-// It will also verify the issuer.
+// It will also verify the issuer, and reject tokens issued to blocked users with an error matching common.ErrUserBlocked.
+// Only the blocked status written in the token when it was issued is checked: a token issued before KobbleUsers.Block
+// keeps verifying until it expires. Use KobbleUsers.GetById to check the current status of the user when it matters.
+// Impersonation tokens, created with KobbleUsers.CreateImpersonationToken, are accepted and expose their actor in Act.
 // By default, it will accept any audience (any OAuth application of your Kobble project).
 // If you want to restrict the audience, you can pass the applicationId in the options.
 //
@@ -70,9 +83,14 @@ func (auth KobbleAuth) VerifyAccessToken(token string) (VerifyAccessTokenResult,
 	}
 
 	if claims, ok := tk.Claims.(*rawAccessTokenPayloadClaims); ok && tk.Valid {
+		if claims.IsBlocked {
+			return VerifyAccessTokenResult{}, newAccessTokenVerificationError(common.ErrUserBlocked)
+		}
+
 		return VerifyAccessTokenResult{
 			UserID:    claims.Sub,
 			ProjectID: claims.ProjectID,
+			Act:       claims.Act,
 			Claims:    *claims,
 		}, nil
 	}
@@ -92,7 +110,9 @@ func parseClaimsDate(date string) (string, error) {
 
 // VerifyIdToken verify an ID Token generated by your OAuth Application or throw an error.
 // This method will verify the token signature and expiration time.
-// It will also verify the issuer.
+// It will also verify the issuer, and reject tokens issued to blocked users with an error matching common.ErrUserBlocked.
+// Only the blocked status written in the token when it was issued is checked: a token issued before KobbleUsers.Block
+// keeps verifying until it expires. Use KobbleUsers.GetById to check the current status of the user when it matters.
 // By default, it will accept any audience (any OAuth application of your Kobble project).
 // If you want to restrict the audience, you can pass the applicationId in the options.
 //
@@ -117,6 +137,10 @@ func (auth KobbleAuth) VerifyIdToken(token string) (VerifyIdTokenResult, error)
 	}
 
 	if claims, ok := tk.Claims.(*rawIdTokenPayloadClaims); ok && tk.Valid {
+		if claims.IsBlocked {
+			return VerifyIdTokenResult{}, newIdTokenVerificationError(common.ErrUserBlocked)
+		}
+
 		updatedAt, err := parseClaimsDate(claims.UpdatedAt)
 		if err != nil {
 			return VerifyIdTokenResult{}, newIdTokenVerificationError(err)
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"github.com/kobble-io/go-admin/internal/testutil"
+	"github.com/kobble-io/go-admin/utils"
+	"testing"
+	"time"
+)
+
+var whoamiResponses = map[string]any{"/auth/whoami": Whoami{ProjectId: "project"}}
+
+func TestProjectCacheLifetime(t *testing.T) {
+	projectCacheTtl = 100 * time.Millisecond
+	projectCacheStaleTtl = 200 * time.Millisecond
+	t.Cleanup(func() {
+		projectCacheTtl = 50 * time.Minute
+		projectCacheStaleTtl = 10 * time.Minute
+	})
+
+	testutil.RunLifetimeCases(t, testutil.StaleLifetimeCases, "/auth/whoami", whoamiResponses, func(client *utils.HttpClient) func() error {
+		auth := NewKobbleAuth(Config{Http: client})
+		return func() error {
+			_, err := auth.getProjectId()
+			return err
+		}
+	})
+}
+
+func TestProjectCacheDefaultLifetime(t *testing.T) {
+	server := testutil.NewServer(t, whoamiResponses)
+	cache := testutil.NewRecordingCache[string]()
+	auth := NewKobbleAuth(Config{Http: server.Http(), ProjectCache: cache})
+
+	for i := 0; i < 3; i++ {
+		projectId, err := auth.getProjectId()
+		if err != nil {
+			t.Fatalf("getProjectId() error = %v", err)
+		}
+		if projectId != "project" {
+			t.Fatalf("getProjectId() = %q, want %q", projectId, "project")
+		}
+	}
+
+	if got := server.Calls("/auth/whoami"); got != 1 {
+		t.Errorf("calls to /auth/whoami = %d, want 1", got)
+	}
+	// The project ID is fresh for 50 minutes, then served stale for 10 more.
+	if got := cache.Ttl("projectId"); got != time.Hour {
+		t.Errorf("project ID cached for %s, want %s", got, time.Hour)
+	}
+}
+
+func TestProjectCacheLoadTimeout(t *testing.T) {
+	projectCacheLoadTimeout = 50 * time.Millisecond
+	t.Cleanup(func() {
+		projectCacheLoadTimeout = 30 * time.Second
+	})
+
+	server := testutil.NewServer(t, map[string]any{"/auth/whoami": testutil.Hang})
+	auth := NewKobbleAuth(Config{Http: server.Http()})
+
+	testutil.RunLoadTimeout(t, projectCacheLoadTimeout, func() error {
+		_, err := auth.getProjectId()
+		return err
+	})
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/kobble-io/go-admin/common"
 	"github.com/kobble-io/go-admin/utils"
 )
 
@@ -9,9 +10,13 @@ import (
 	"time"
 )
 
+// VerifyAccessTokenResult is the result of a verified access token.
+//
+//   - Act is the actor of an impersonation token, or nil when the token was issued to the user themselves.
 type VerifyAccessTokenResult struct {
 	UserID    string                      `json:"user_id"`
 	ProjectID string                      `json:"project_id"`
+	Act       *common.Actor               `json:"act,omitempty"`
 	Claims    rawAccessTokenPayloadClaims `json:"claims"`
 }
 
@@ -32,9 +37,13 @@ type VerifyIdTokenResult struct {
 	Claims rawIdTokenPayloadClaims `json:"claims"`
 }
 
+// Config is the configuration of the KobbleAuth client.
+//
+//   - ProjectCache is the cache holding the ID of the project. Defaults to an in-memory cache.
 type Config struct {
-	Http    *utils.HttpClient
-	BaseURL string
+	Http         *utils.HttpClient
+	BaseURL      string
+	ProjectCache utils.Cache[string]
 }
 
 type Whoami struct {
@@ -43,10 +52,6 @@ type Whoami struct {
 	UserId      string
 }
 
-type projectCache struct {
-	ProjectID string
-}
-
 type rawIdTokenPayloadClaims struct {
 	Sub        string `json:"sub"`
 	ID         string `json:"id"`
@@ -54,6 +59,7 @@ type rawIdTokenPayloadClaims struct {
 	Name       string `json:"name"`
 	PictureURL string `json:"picture_url"`
 	IsVerified bool   `json:"is_verified"`
+	IsBlocked  bool   `json:"is_blocked,omitempty"`
 	StripeID   string `json:"stripe_id"`
 	UpdatedAt  string `json:"updated_at"`
 	CreatedAt  string `json:"created_at"`
@@ -89,13 +95,15 @@ func (r rawIdTokenPayloadClaims) GetAudience() (jwt.ClaimStrings, error) {
 }
 
 type rawAccessTokenPayloadClaims struct {
-	Sub       string `json:"sub"`
-	ProjectID string `json:"project_id"`
-	Exp       int64  `json:"exp"`
-	Iat       int64  `json:"iat"`
-	Nbf       int64  `json:"nbf"`
-	Iss       string `json:"iss"`
-	Aud       string `json:"aud"`
+	Sub       string        `json:"sub"`
+	ProjectID string        `json:"project_id"`
+	IsBlocked bool          `json:"is_blocked,omitempty"`
+	Act       *common.Actor `json:"act,omitempty"`
+	Exp       int64         `json:"exp"`
+	Iat       int64         `json:"iat"`
+	Nbf       int64         `json:"nbf"`
+	Iss       string        `json:"iss"`
+	Aud       string        `json:"aud"`
 }
 
 func (r rawAccessTokenPayloadClaims) GetExpirationTime() (*jwt.NumericDate, error) {
@@ -0,0 +1,9 @@
+package common
+
+import "errors"
+
+// UserBlockedErrorName is the name of the error returned by the Kobble API for a blocked user.
+const UserBlockedErrorName = "USER_BLOCKED"
+
+// ErrUserBlocked is returned by the SDK when the user being checked or authenticated is blocked.
+var ErrUserBlocked = errors.New("user is blocked")
@@ -14,3 +14,24 @@ type Pagination[T any] struct {
 	Data    []T
 	HasNext bool
 }
+
+// Actor is the "act" claim (RFC 8693) of a token issued to someone acting on behalf of its subject,
+// for instance a support agent impersonating a user.
+//
+//   - Sub is the unique identifier of the actor
+//   - Reason is the reason given for the impersonation, when known
+type Actor struct {
+	Sub    string `json:"sub"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Product is a product of your Kobble project, shared by all the services of the SDK.
+//
+//   - ID is the unique identifier of the product
+//   - Name is the name of the product
+//   - Price is the amount of the default price of the product, when known
+type Product struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name,omitempty"`
+	Price float64 `json:"price,omitempty"`
+}
@@ -1,46 +1,69 @@
 package gateway
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"github.com/kobble-io/go-admin/common"
 	"github.com/kobble-io/go-admin/utils"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 // KobbleGateway is the struct that holds the configuration for the gateway service
 type KobbleGateway struct {
 	config   Config
-	keyCache *utils.Cache[keyInfo]
-	issuer   string
+	keyCache *utils.LoadingCache[PublicKeyInfo]
+	// parsedKey memoizes the last parsed key, so that the PEM is only parsed again when the key changes.
+	parsedKey atomic.Pointer[parsedKeyInfo]
+	issuer    string
 }
 
+type parsedKeyInfo struct {
+	info PublicKeyInfo
+	key  *keyInfo
+}
+
+// The lifetimes of the cached public key, and the time allowed to fetch it. They are variables so that tests can shorten them.
+var (
+	keyCacheTtl         = 15 * time.Minute
+	keyCacheStaleTtl    = 5 * time.Minute
+	keyCacheLoadTimeout = 30 * time.Second
+)
+
 // NewKobbleGateway creates a new instance of KobbleGateway
 func NewKobbleGateway(config Config) *KobbleGateway {
-	defaultTtl := 15 * time.Minute / time.Second
+	keyCache := config.KeyCache
+	if keyCache == nil {
+		keyCache = utils.NewMemoryCache[PublicKeyInfo](utils.CacheConfig{})
+	}
 	return &KobbleGateway{
 		config: config,
-		keyCache: utils.NewCache[keyInfo](utils.CacheConfig{
-			DefaultTtl: &defaultTtl,
+		keyCache: utils.NewLoadingCache(keyCache, utils.LoadingCacheConfig{
+			Ttl:         keyCacheTtl,
+			StaleTtl:    keyCacheStaleTtl,
+			LoadTimeout: keyCacheLoadTimeout,
 		}),
 		issuer: "gateway.kobble.io",
 	}
 }
 
-func (k *KobbleGateway) fetchKeyInfo() (*keyInfo, error) {
-	var result struct {
-		Pem       string `json:"pem"`
-		ProjectID string `json:"project_id"`
-	}
-	err := k.config.Http.GetJson("/gateway/getPublicKey", nil, &result, http.StatusOK)
+func (k *KobbleGateway) fetchKeyInfo(ctx context.Context) (*PublicKeyInfo, error) {
+	var result PublicKeyInfo
+	err := k.config.Http.GetJsonWithContext(ctx, "/gateway/getPublicKey", nil, &result, http.StatusOK)
 	if err != nil {
 		return nil, err
 	}
 
-	block, _ := pem.Decode([]byte(result.Pem))
+	return &result, nil
+}
+
+func parseKeyInfo(info PublicKeyInfo) (*keyInfo, error) {
+	block, _ := pem.Decode([]byte(info.Pem))
 	if block == nil || block.Type != "PUBLIC KEY" {
 		return nil, errors.New("failed to decode PEM block containing public key")
 	}
@@ -57,23 +80,44 @@ func (k *KobbleGateway) fetchKeyInfo() (*keyInfo, error) {
 
 	return &keyInfo{
 		Key:       ecdsaPub,
-		ProjectID: result.ProjectID,
+		ProjectID: info.ProjectID,
 	}, nil
 }
 
 func (k *KobbleGateway) getKeyInfo() (*keyInfo, error) {
-	info := k.keyCache.Get("default")
-	if info != nil {
-		return info, nil
+	info, err := k.keyCache.Get(context.Background(), "default", func(ctx context.Context) (PublicKeyInfo, error) {
+		data, err := k.fetchKeyInfo(ctx)
+		if err != nil {
+			return PublicKeyInfo{}, err
+		}
+
+		// Make sure a broken key never lands in the cache.
+		if _, err := k.parseKeyInfo(*data); err != nil {
+			return PublicKeyInfo{}, err
+		}
+
+		return *data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return k.parseKeyInfo(info)
+}
+
+// parseKeyInfo parses info, reusing the last parsed key when info did not change.
+func (k *KobbleGateway) parseKeyInfo(info PublicKeyInfo) (*keyInfo, error) {
+	if parsed := k.parsedKey.Load(); parsed != nil && parsed.info == info {
+		return parsed.key, nil
 	}
 
-	data, err := k.fetchKeyInfo()
+	key, err := parseKeyInfo(info)
 	if err != nil {
 		return nil, err
 	}
 
-	k.keyCache.Set("default", *data, nil)
-	return data, nil
+	k.parsedKey.Store(&parsedKeyInfo{info: info, key: key})
+	return key, nil
 }
 
 // ParseToken verify and parse the payload of a Kobble gateway token.
@@ -85,7 +129,11 @@ func (k *KobbleGateway) getKeyInfo() (*keyInfo, error) {
 //   - The 'iss' claim matches the issuer used by Kobble to forge such tokens
 //   - The token is not expired
 //   - The signature is valid (i.e., that this token has not been tampered with and is intended for your project)
+//   - The user was not blocked when the token was issued, otherwise common.ErrUserBlocked is returned
 //
+// Only the blocked status written in the token when it was issued is checked: a token issued before KobbleUsers.Block
+// keeps verifying until it expires. Use KobbleUsers.GetById to check the current status of the user when it matters.
+// Impersonation tokens, created with KobbleUsers.CreateImpersonationToken, are accepted and expose their actor in Act.
 // Although it is not recommended, some of these verifications can be skipped by passing special options.
 func (k *KobbleGateway) ParseToken(tokenString string, options ParseTokenOptions) (TokenPayload, error) {
 	ki, err := k.getKeyInfo()
@@ -117,5 +165,9 @@ func (k *KobbleGateway) ParseToken(tokenString string, options ParseTokenOptions
 		return TokenPayload{}, err
 	}
 
+	if raw.User.IsBlocked {
+		return TokenPayload{}, common.ErrUserBlocked
+	}
+
 	return raw, nil
 }
@@ -0,0 +1,70 @@
+package gateway
+
+import (
+	"github.com/kobble-io/go-admin/internal/testutil"
+	"github.com/kobble-io/go-admin/utils"
+	"testing"
+	"time"
+)
+
+func keyResponses(t *testing.T) map[string]any {
+	return map[string]any{
+		"/gateway/getPublicKey": PublicKeyInfo{Pem: testutil.NewPublicKeyPem(t), ProjectID: "project"},
+	}
+}
+
+func TestKeyCacheLifetime(t *testing.T) {
+	keyCacheTtl = 100 * time.Millisecond
+	keyCacheStaleTtl = 200 * time.Millisecond
+	t.Cleanup(func() {
+		keyCacheTtl = 15 * time.Minute
+		keyCacheStaleTtl = 5 * time.Minute
+	})
+
+	testutil.RunLifetimeCases(t, testutil.StaleLifetimeCases, "/gateway/getPublicKey", keyResponses(t), func(client *utils.HttpClient) func() error {
+		gateway := NewKobbleGateway(Config{Http: client})
+		return func() error {
+			_, err := gateway.getKeyInfo()
+			return err
+		}
+	})
+}
+
+func TestKeyCacheDefaultLifetime(t *testing.T) {
+	server := testutil.NewServer(t, keyResponses(t))
+	cache := testutil.NewRecordingCache[PublicKeyInfo]()
+	gateway := NewKobbleGateway(Config{Http: server.Http(), KeyCache: cache})
+
+	for i := 0; i < 3; i++ {
+		key, err := gateway.getKeyInfo()
+		if err != nil {
+			t.Fatalf("getKeyInfo() error = %v", err)
+		}
+		if key.ProjectID != "project" {
+			t.Fatalf("getKeyInfo().ProjectID = %q, want %q", key.ProjectID, "project")
+		}
+	}
+
+	if got := server.Calls("/gateway/getPublicKey"); got != 1 {
+		t.Errorf("calls to /gateway/getPublicKey = %d, want 1", got)
+	}
+	// The key is fresh for 15 minutes, then served stale for 5 more.
+	if got := cache.Ttl("default"); got != 20*time.Minute {
+		t.Errorf("key cached for %s, want %s", got, 20*time.Minute)
+	}
+}
+
+func TestKeyCacheLoadTimeout(t *testing.T) {
+	keyCacheLoadTimeout = 50 * time.Millisecond
+	t.Cleanup(func() {
+		keyCacheLoadTimeout = 30 * time.Second
+	})
+
+	server := testutil.NewServer(t, map[string]any{"/gateway/getPublicKey": testutil.Hang})
+	gateway := NewKobbleGateway(Config{Http: server.Http()})
+
+	testutil.RunLoadTimeout(t, keyCacheLoadTimeout, func() error {
+		_, err := gateway.getKeyInfo()
+		return err
+	})
+}
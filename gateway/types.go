@@ -1,6 +1,9 @@
 package gateway
 
-import "github.com/kobble-io/go-admin/utils"
+import (
+	"github.com/kobble-io/go-admin/common"
+	"github.com/kobble-io/go-admin/utils"
+)
 
 import (
 	"crypto/ecdsa"
@@ -15,17 +18,22 @@ type (
 	}
 
 	TokenProduct struct {
-		ID     string              `json:"id"`
+		common.Product
 		Quotas []TokenProductQuota `json:"quotas"`
 	}
 
+	// TokenPayload is the payload of a gateway token.
+	//
+	//   - Act is the actor of an impersonation token, or nil when the token was issued to the user themselves.
 	TokenPayload struct {
-		ProjectID string `json:"project_id"`
+		ProjectID string        `json:"project_id"`
+		Act       *common.Actor `json:"act,omitempty"`
 		User      struct {
-			Email    string         `json:"email"`
-			ID       string         `json:"id"`
-			Name     *string        `json:"name"`
-			Products []TokenProduct `json:"products"`
+			Email     string         `json:"email"`
+			ID        string         `json:"id"`
+			Name      *string        `json:"name"`
+			IsBlocked bool           `json:"is_blocked,omitempty"`
+			Products  []TokenProduct `json:"products"`
 		} `json:"user"`
 	}
 
@@ -36,12 +44,22 @@ type (
 		VerifySignature bool `json:"verify_signature,omitempty"`
 	}
 
+	// PublicKeyInfo is the public key used to sign the gateway tokens of a project, as returned by the Kobble API.
+	PublicKeyInfo struct {
+		Pem       string `json:"pem"`
+		ProjectID string `json:"project_id"`
+	}
+
 	keyInfo struct {
 		Key       *ecdsa.PublicKey `json:"key"`
 		ProjectID string           `json:"project_id"`
 	}
 
+	// Config is the configuration of the gateway service.
+	//
+	//   - KeyCache is the cache holding the public key of the project. Defaults to an in-memory cache.
 	Config struct {
-		Http *utils.HttpClient
+		Http     *utils.HttpClient
+		KeyCache utils.Cache[PublicKeyInfo]
 	}
 )
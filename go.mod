@@ -2,10 +2,10 @@ module github.com/kobble-io/go-admin
 
 go 1.22
 
-
 require (
-	github.com/MicahParks/jwkset v0.5.18 // indirect
-	github.com/MicahParks/keyfunc/v3 v3.3.3 // indirect
-	github.com/golang-jwt/jwt/v5 v5.2.0 // indirect
-	golang.org/x/time v0.5.0 // indirect
+	github.com/MicahParks/keyfunc/v3 v3.3.3
+	github.com/golang-jwt/jwt/v5 v5.2.0
+	golang.org/x/time v0.5.0
 )
+
+require github.com/MicahParks/jwkset v0.5.18 // indirect
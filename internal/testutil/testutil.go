@@ -0,0 +1,235 @@
+// Package testutil holds the helpers shared by the tests of the SDK.
+package testutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server is a fake Kobble API answering each path with a fixed JSON response and counting the requests made to it.
+type Server struct {
+	*httptest.Server
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// NewServer starts a Server answering each path of responses with its value encoded as JSON.
+// A value implementing http.Handler handles the request instead. Requests to other paths fail the test.
+func NewServer(t *testing.T, responses map[string]any) *Server {
+	t.Helper()
+
+	s := &Server{calls: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		s.mu.Lock()
+		s.calls[r.URL.Path]++
+		s.mu.Unlock()
+
+		if handler, ok := response.(http.Handler); ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Http returns a client of the Kobble API sending its requests to the server.
+func (s *Server) Http() *utils.HttpClient {
+	return utils.NewHttpClient(utils.HttpClientConfig{BaseURL: s.URL, Secret: "secret"})
+}
+
+// Calls returns the number of requests made to path so far.
+func (s *Server) Calls(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[path]
+}
+
+// LifetimeCase is a sequence of calls to a cached value, each made after waiting for its duration,
+// and the number of requests they should make to the Kobble API.
+type LifetimeCase struct {
+	Name      string
+	Waits     []time.Duration
+	WantCalls int
+}
+
+// StaleLifetimeCases are the cases of a value cached for 100 milliseconds then served stale for 200 milliseconds.
+var StaleLifetimeCases = []LifetimeCase{
+	{
+		Name:      "repeated calls within the ttl are served from the cache",
+		Waits:     []time.Duration{0, 0, 0, 20 * time.Millisecond},
+		WantCalls: 1,
+	},
+	{
+		Name:      "a call past the ttl serves the stale value and refreshes it once",
+		Waits:     []time.Duration{0, 150 * time.Millisecond, 0},
+		WantCalls: 2,
+	},
+	{
+		Name:      "a call past the ttl and the stale ttl loads the value again",
+		Waits:     []time.Duration{0, 400 * time.Millisecond},
+		WantCalls: 2,
+	},
+}
+
+// RunLifetimeCases runs each case against a new server answering responses, and checks the requests made to path.
+// newCall creates the client under test and returns the call reading the cached value.
+func RunLifetimeCases(t *testing.T, cases []LifetimeCase, path string, responses map[string]any, newCall func(client *utils.HttpClient) func() error) {
+	t.Helper()
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			server := NewServer(t, responses)
+			call := newCall(server.Http())
+
+			for _, wait := range tt.Waits {
+				time.Sleep(wait)
+				if err := call(); err != nil {
+					t.Fatalf("call error = %v", err)
+				}
+			}
+
+			// Let background refreshes complete.
+			time.Sleep(50 * time.Millisecond)
+			if got := server.Calls(path); got != tt.WantCalls {
+				t.Errorf("calls to %s = %d, want %d", path, got, tt.WantCalls)
+			}
+		})
+	}
+}
+
+// RecordingCache is a MemoryCache recording the ttl of the last Set of each key.
+type RecordingCache[T any] struct {
+	*utils.MemoryCache[T]
+	mu   sync.Mutex
+	ttls map[string]time.Duration
+}
+
+func NewRecordingCache[T any]() *RecordingCache[T] {
+	return &RecordingCache[T]{
+		MemoryCache: utils.NewMemoryCache[T](utils.CacheConfig{}),
+		ttls:        map[string]time.Duration{},
+	}
+}
+
+func (c *RecordingCache[T]) Set(ctx context.Context, key string, data T, ttl time.Duration) error {
+	c.mu.Lock()
+	c.ttls[key] = ttl
+	c.mu.Unlock()
+	return c.MemoryCache.Set(ctx, key, data, ttl)
+}
+
+// Ttl returns the ttl of the last Set of key.
+func (c *RecordingCache[T]) Ttl(key string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ttls[key]
+}
+
+// MapStore is an in-process utils.KeyValueStore standing in for a store shared by several processes, such as Redis.
+type MapStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func NewMapStore() *MapStore {
+	return &MapStore{values: map[string][]byte{}}
+}
+
+func (s *MapStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key], nil
+}
+
+func (s *MapStore) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+func (s *MapStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	return nil
+}
+
+// Keys returns the keys held by the store.
+func (s *MapStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// NewPublicKeyPem returns a new P-256 public key, PEM encoded as the Kobble API serves the gateway key.
+func NewPublicKeyPem(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// Hang is a response that does not answer until the request is canceled, or for 5 seconds so that the server can be closed.
+var Hang = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-r.Context().Done():
+	case <-time.After(5 * time.Second):
+	}
+})
+
+// RunLoadTimeout checks that concurrent callers waiting for a load that hangs all fail once the load times out.
+func RunLoadTimeout(t *testing.T, timeout time.Duration, call func() error) {
+	t.Helper()
+
+	const callers = 3
+	errs := make(chan error, callers)
+	start := time.Now()
+	for i := 0; i < callers; i++ {
+		go func() {
+			errs <- call()
+		}()
+	}
+
+	for i := 0; i < callers; i++ {
+		select {
+		case err := <-errs:
+			if err == nil {
+				t.Errorf("call error = nil, want the load to time out")
+			}
+		case <-time.After(timeout + time.Second):
+			t.Fatalf("call still blocked %s after the load timeout", time.Since(start)-timeout)
+		}
+	}
+}
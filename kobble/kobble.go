@@ -3,6 +3,9 @@ package kobble
 import (
 	"github.com/kobble-io/go-admin/auth"
 	"github.com/kobble-io/go-admin/gateway"
+	"github.com/kobble-io/go-admin/permissions"
+	"github.com/kobble-io/go-admin/products"
+	"github.com/kobble-io/go-admin/quotas"
 	"github.com/kobble-io/go-admin/users"
 	"github.com/kobble-io/go-admin/utils"
 	"github.com/kobble-io/go-admin/webhooks"
@@ -18,12 +21,18 @@ const DefaultBaseUrl = "https://sdk.kobble.io"
 //   - Users is the users service that allows you to interact with the users service.
 //   - Webhooks is the webhooks service that allows you to interact with the webhooks service.
 //   - Auth is the auth service that allows you to interact with the auth service.
+//   - Quotas is the quotas service that allows you to interact with the quotas of your project.
+//   - Permissions is the permissions service that allows you to introspect the permissions of your project.
+//   - Products is the products service that allows you to interact with the products of your project.
 type Kobble struct {
-	http     *utils.HttpClient
-	Gateway  *gateway.KobbleGateway
-	Users    *users.KobbleUsers
-	Webhooks *webhooks.KobbleWebhooks
-	Auth     *auth.KobbleAuth
+	http        *utils.HttpClient
+	Gateway     *gateway.KobbleGateway
+	Users       *users.KobbleUsers
+	Webhooks    *webhooks.KobbleWebhooks
+	Auth        *auth.KobbleAuth
+	Quotas      *quotas.KobbleQuotas
+	Permissions *permissions.KobblePermission
+	Products    *products.KobbleProducts
 }
 
 // New is the constructor for the Kobble SDK.
@@ -41,15 +50,38 @@ func New(secret string, options Options) *Kobble {
 		BaseURL: baseURL,
 		Secret:  secret,
 	})
+	gatewayConfig := gateway.Config{Http: http}
+	usersConfig := users.Config{Http: http, AuditLogger: options.AuditLogger}
+	authConfig := auth.Config{
+		Http:    http,
+		BaseURL: baseURL,
+	}
+	if store := options.CacheStore; store != nil {
+		gatewayConfig.KeyCache = utils.NewKeyValueCache[gateway.PublicKeyInfo](store, "kobble:gateway:key:", 0)
+		usersConfig.PermissionsCache = utils.NewKeyValueCache[[]permissions.Permission](store, "kobble:users:permissions:", 0)
+		usersConfig.QuotasCache = utils.NewKeyValueCache[[]users.QuotaUsage](store, "kobble:users:quotas:", 0)
+		authConfig.ProjectCache = utils.NewKeyValueCache[string](store, "kobble:auth:project:", 0)
+	}
+	usersClient := users.NewKobbleUsers(usersConfig)
+	quotasConfig := quotas.Config{
+		Http: http,
+		OnUsageReset: func(userId string) {
+			if userId == "" {
+				usersClient.InvalidateAllQuotas()
+				return
+			}
+			usersClient.InvalidateUser(userId)
+		},
+	}
 	return &Kobble{
-		http:     http,
-		Gateway:  gateway.NewKobbleGateway(gateway.Config{Http: http}),
-		Users:    users.NewKobbleUsers(users.Config{Http: http}),
-		Webhooks: webhooks.NewKobbleWebhooks(),
-		Auth: auth.NewKobbleAuth(auth.Config{
-			Http:    http,
-			BaseURL: baseURL,
-		}),
+		http:        http,
+		Gateway:     gateway.NewKobbleGateway(gatewayConfig),
+		Users:       usersClient,
+		Webhooks:    webhooks.NewKobbleWebhooks(),
+		Auth:        auth.NewKobbleAuth(authConfig),
+		Quotas:      quotas.NewKobbleQuotas(quotasConfig),
+		Permissions: permissions.NewPermission(http),
+		Products:    products.NewKobbleProducts(products.Config{Http: http}),
 	}
 }
 
@@ -0,0 +1,107 @@
+package kobble
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/kobble-io/go-admin/auth"
+	"github.com/kobble-io/go-admin/gateway"
+	"github.com/kobble-io/go-admin/internal/testutil"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewSharesCacheStore(t *testing.T) {
+	pemKey := testutil.NewPublicKeyPem(t)
+	var mu sync.Mutex
+	calls := map[string]int{}
+	down := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/discovery/p/project/apps/keys" {
+			_, _ = w.Write([]byte(`{"keys":[]}`))
+			return
+		}
+
+		mu.Lock()
+		isDown := down
+		if !isDown {
+			calls[r.URL.Path]++
+		}
+		mu.Unlock()
+		if isDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/gateway/getPublicKey":
+			_ = json.NewEncoder(w).Encode(gateway.PublicKeyInfo{Pem: pemKey, ProjectID: "project"})
+		case "/auth/whoami":
+			_ = json.NewEncoder(w).Encode(auth.Whoami{ProjectId: "project", UserId: "user"})
+		case "/users/listPermissions":
+			_, _ = w.Write([]byte(`[]`))
+		case "/users/listQuotas":
+			_, _ = w.Write([]byte(`{"quotas":[]}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	store := testutil.NewMapStore()
+	baseURL := server.URL
+
+	// Two SDK instances sharing a store behave like two replicas of a service sharing Redis:
+	// once the first one has fetched everything, the second one is served from the store
+	// even though the Kobble API is down.
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			mu.Lock()
+			down = true
+			mu.Unlock()
+		}
+
+		k := New("secret", Options{BaseApiUrl: &baseURL, CacheStore: store})
+		var httpErr *utils.HttpError
+
+		// The tokens are rejected, but only once the key and the project ID are known.
+		if _, err := k.Gateway.ParseToken("not a token", gateway.ParseTokenOptions{}); errors.As(err, &httpErr) {
+			t.Errorf("instance %d: ParseToken() error = %v, want the key to be read from the store", i, err)
+		}
+		if _, err := k.Auth.VerifyAccessToken("not a token"); errors.As(err, &httpErr) {
+			t.Errorf("instance %d: VerifyAccessToken() error = %v, want the project ID to be read from the store", i, err)
+		}
+		if _, err := k.Users.ListPermissions("u1", nil); err != nil {
+			t.Errorf("instance %d: ListPermissions() error = %v", i, err)
+		}
+		if _, err := k.Users.ListQuotas("u1", nil); err != nil {
+			t.Errorf("instance %d: ListQuotas() error = %v", i, err)
+		}
+		k.Users.Close()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/gateway/getPublicKey", "/auth/whoami", "/users/listPermissions", "/users/listQuotas"} {
+		if got := calls[path]; got != 1 {
+			t.Errorf("calls to %s = %d, want 1", path, got)
+		}
+	}
+
+	keys := store.Keys()
+	for _, prefix := range []string{"kobble:gateway:key:", "kobble:auth:project:", "kobble:users:permissions:", "kobble:users:quotas:"} {
+		found := false
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("store keys = %v, want a key prefixed with %s", keys, prefix)
+		}
+	}
+}
@@ -1,5 +1,18 @@
 package kobble
 
+import (
+	"github.com/kobble-io/go-admin/utils"
+	"log/slog"
+)
+
+// Options is the configuration of the Kobble SDK.
+//
+//   - BaseApiUrl overrides the URL of the Kobble SDK API.
+//   - CacheStore is a shared key/value store (e.g. Redis or memcached) used for every cache of the SDK.
+//     When nil, each service uses its own in-memory cache.
+//   - AuditLogger receives the audit records of the SDK, such as impersonation token requests. When nil, nothing is logged.
 type Options struct {
-	BaseApiUrl *string
+	BaseApiUrl  *string
+	CacheStore  utils.KeyValueStore
+	AuditLogger *slog.Logger
 }
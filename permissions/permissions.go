@@ -1,8 +1,15 @@
 package permissions
 
-import "github.com/kobble-io/go-admin/utils"
+import (
+	"github.com/kobble-io/go-admin/common"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+)
 
 // KobblePermission is the struct that holds the configuration for the permission service
+//
+// You can use this client to introspect the permissions defined in your Kobble dashboard,
+// the products granting them and the users holding them.
 type KobblePermission struct {
 	config permissionConfig
 }
@@ -15,3 +22,90 @@ func NewPermission(http *utils.HttpClient) *KobblePermission {
 		},
 	}
 }
+
+// ListAll fetches the permissions defined in your Kobble project.
+//
+// Options:
+//   - Page: The page number to fetch. Defaults to 1.
+//   - Limit: The number of permissions to fetch per page. Defaults to 50.
+func (k KobblePermission) ListAll(options *ListPermissionsOptions) (common.Pagination[Permission], error) {
+	params := utils.PageParams(0, 0)
+	if options != nil {
+		params = utils.PageParams(options.Page, options.Limit)
+	}
+
+	var result common.Pagination[Permission]
+	err := k.config.http.GetJson("/permissions/list", params, &result, http.StatusOK)
+	if err != nil {
+		return common.Pagination[Permission]{}, err
+	}
+
+	return result, nil
+}
+
+// GetById fetches a permission by its ID.
+//
+//   - @param permissionId - The unique identifier of the permission to fetch.
+func (k KobblePermission) GetById(permissionId string) (*Permission, error) {
+	var result Permission
+	err := k.config.http.GetJson("/permissions/findById", map[string]string{
+		"permissionId": permissionId,
+	}, &result, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetByName fetches a permission by its name.
+//
+//   - @param permissionName - The name of the permission to fetch.
+func (k KobblePermission) GetByName(permissionName string) (*Permission, error) {
+	var result Permission
+	err := k.config.http.GetJson("/permissions/findByName", map[string]string{
+		"permissionName": permissionName,
+	}, &result, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ListProducts fetches the products granting a permission.
+//
+//   - @param permissionId - The unique identifier of the permission.
+func (k KobblePermission) ListProducts(permissionId string) ([]common.Product, error) {
+	var result []common.Product
+	err := k.config.http.GetJson("/permissions/listProducts", map[string]string{
+		"permissionId": permissionId,
+	}, &result, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListUsers fetches the users holding a permission through the products they are assigned to.
+//
+// Options:
+//   - Page: The page number to fetch. Defaults to 1.
+//   - Limit: The number of users to fetch per page. Defaults to 50.
+//   - @param permissionId - The unique identifier of the permission.
+func (k KobblePermission) ListUsers(permissionId string, options *ListUsersOptions) (common.Pagination[User], error) {
+	params := utils.PageParams(0, 0)
+	if options != nil {
+		params = utils.PageParams(options.Page, options.Limit)
+	}
+	params["permissionId"] = permissionId
+
+	var result common.Pagination[User]
+	err := k.config.http.GetJson("/permissions/listUsers", params, &result, http.StatusOK)
+	if err != nil {
+		return common.Pagination[User]{}, err
+	}
+
+	return result, nil
+}
@@ -8,6 +8,24 @@ type (
 	}
 
 	Permission struct {
-		Name string
+		ID   string `json:"id,omitempty"`
+		Name string `json:"name"`
+	}
+
+	// User is a user holding a permission.
+	User struct {
+		ID    string  `json:"id"`
+		Email string  `json:"email"`
+		Name  *string `json:"name"`
+	}
+
+	ListPermissionsOptions struct {
+		Limit int `json:"limit,omitempty"`
+		Page  int `json:"page,omitempty"`
+	}
+
+	ListUsersOptions struct {
+		Limit int `json:"limit,omitempty"`
+		Page  int `json:"page,omitempty"`
 	}
 )
@@ -1,9 +1,79 @@
 package products
 
+import (
+	"github.com/kobble-io/go-admin/common"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+)
+
 // KobbleProducts is the struct that holds the configuration for the Product
-type KobbleProducts struct{}
+//
+// You can use this client to list the products of your Kobble project, along with their prices,
+// permissions, quotas and subscribers.
+type KobbleProducts struct {
+	config Config
+}
+
+// NewKobbleProducts creates a new instance of KobbleProducts
+func NewKobbleProducts(config Config) *KobbleProducts {
+	return &KobbleProducts{
+		config: config,
+	}
+}
+
+// ListAll fetches the products of your Kobble project.
+//
+// Options:
+//   - Page: The page number to fetch. Defaults to 1.
+//   - Limit: The number of products to fetch per page. Defaults to 50.
+func (k KobbleProducts) ListAll(options *ListProductsOptions) (common.Pagination[Product], error) {
+	params := utils.PageParams(0, 0)
+	if options != nil {
+		params = utils.PageParams(options.Page, options.Limit)
+	}
+
+	var result common.Pagination[Product]
+	err := k.config.Http.GetJson("/products/list", params, &result, http.StatusOK)
+	if err != nil {
+		return common.Pagination[Product]{}, err
+	}
+
+	return result, nil
+}
+
+// GetById fetches a product by its ID, along with its prices, permissions and quota definitions.
+//
+//   - @param productId - The unique identifier of the product to fetch.
+func (k KobbleProducts) GetById(productId string) (*ProductDetails, error) {
+	var result ProductDetails
+	err := k.config.Http.GetJson("/products/findById", map[string]string{
+		"productId": productId,
+	}, &result, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ListSubscribers fetches the users assigned to a product.
+//
+// Options:
+//   - Page: The page number to fetch. Defaults to 1.
+//   - Limit: The number of subscribers to fetch per page. Defaults to 50.
+//   - @param productId - The unique identifier of the product.
+func (k KobbleProducts) ListSubscribers(productId string, options *ListSubscribersOptions) (common.Pagination[Subscriber], error) {
+	params := utils.PageParams(0, 0)
+	if options != nil {
+		params = utils.PageParams(options.Page, options.Limit)
+	}
+	params["productId"] = productId
+
+	var result common.Pagination[Subscriber]
+	err := k.config.Http.GetJson("/products/listSubscribers", params, &result, http.StatusOK)
+	if err != nil {
+		return common.Pagination[Subscriber]{}, err
+	}
 
-type Product struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	return result, nil
 }
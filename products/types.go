@@ -0,0 +1,55 @@
+package products
+
+import (
+	"github.com/kobble-io/go-admin/common"
+	"github.com/kobble-io/go-admin/permissions"
+	"github.com/kobble-io/go-admin/quotas"
+	"github.com/kobble-io/go-admin/utils"
+	"time"
+)
+
+// Product is a product of your Kobble project.
+type Product = common.Product
+
+type Config struct {
+	Http *utils.HttpClient
+}
+
+// Price is a price at which a product is sold.
+//
+//   - Amount is expressed in the major unit of the currency (e.g. 9.99).
+//   - Interval is the billing interval of recurring prices (e.g. "month"), or empty for one-time prices.
+type Price struct {
+	ID       string  `json:"id"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	Interval string  `json:"interval,omitempty"`
+}
+
+// ProductDetails is a product along with its prices, the permissions it grants and the quotas it defines.
+type ProductDetails struct {
+	Product
+	Prices      []Price                  `json:"prices"`
+	Permissions []permissions.Permission `json:"permissions"`
+	Quotas      []quotas.Quotas          `json:"quotas"`
+}
+
+// Subscriber is a user assigned to a product.
+type Subscriber struct {
+	UserID    string     `json:"user_id"`
+	Email     string     `json:"email"`
+	Name      *string    `json:"name"`
+	Status    string     `json:"status"`
+	StartedAt time.Time  `json:"started_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type ListProductsOptions struct {
+	Limit int `json:"limit,omitempty"`
+	Page  int `json:"page,omitempty"`
+}
+
+type ListSubscribersOptions struct {
+	Limit int `json:"limit,omitempty"`
+	Page  int `json:"page,omitempty"`
+}
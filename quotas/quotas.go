@@ -1,6 +1,16 @@
 package quotas
 
+import (
+	"github.com/kobble-io/go-admin/common"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"strconv"
+)
+
 // KobbleQuotas is the struct that holds the configuration for the KobbleQuotas service
+//
+// You can use this client to inspect the quotas defined in your Kobble project and manage their usage across all users.
+// To read or change the usage of a single user, use the KobbleUsers client.
 type KobbleQuotas struct {
 	config Config
 }
@@ -11,3 +21,127 @@ func NewKobbleQuotas(config Config) *KobbleQuotas {
 		config: config,
 	}
 }
+
+type ListQuotasOptions struct {
+	Limit int `json:"limit,omitempty"`
+	Page  int `json:"page,omitempty"`
+}
+
+// ListAll fetches the quotas defined in your Kobble project.
+//
+// Options:
+//   - Page: The page number to fetch. Defaults to 1.
+//   - Limit: The number of quotas to fetch per page. Defaults to 50.
+func (k KobbleQuotas) ListAll(options *ListQuotasOptions) (common.Pagination[Quotas], error) {
+	params := utils.PageParams(0, 0)
+	if options != nil {
+		params = utils.PageParams(options.Page, options.Limit)
+	}
+
+	var result common.Pagination[Quotas]
+	err := k.config.Http.GetJson("/quotas/list", params, &result, http.StatusOK)
+	if err != nil {
+		return common.Pagination[Quotas]{}, err
+	}
+
+	return result, nil
+}
+
+// GetByName fetches a quota by its name.
+//
+//   - @param quotaName - The name of the quota to fetch.
+func (k KobbleQuotas) GetByName(quotaName string) (*Quotas, error) {
+	var result Quotas
+	err := k.config.Http.GetJson("/quotas/findByName", map[string]string{
+		"quotaName": quotaName,
+	}, &result, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetAggregatedUsage retrieves the usage of a quota summed over all the users of your project.
+//
+//   - @param quotaName - The name of the quota whose usage is being retrieved.
+func (k KobbleQuotas) GetAggregatedUsage(quotaName string) (AggregatedUsage, error) {
+	var result AggregatedUsage
+	err := k.config.Http.GetJson("/quotas/getAggregatedUsage", map[string]string{
+		"quotaName": quotaName,
+	}, &result, http.StatusOK)
+	if err != nil {
+		return AggregatedUsage{}, err
+	}
+
+	return result, nil
+}
+
+// ResetUsage resets the usage of a quota to zero for a given user.
+//
+// The quota usages of the user cached by the KobbleUsers client of the same Kobble client are invalidated.
+//
+//   - @param userId - The unique identifier for the user whose quota usage is being reset.
+//   - @param quotaName - The name of the quota to reset.
+func (k KobbleQuotas) ResetUsage(userId string, quotaName string) error {
+	err := k.config.Http.PostJson("/quotas/resetUsage", map[string]any{
+		"userId":    userId,
+		"quotaName": quotaName,
+	}, nil, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+
+	if k.config.OnUsageReset != nil {
+		k.config.OnUsageReset(userId)
+	}
+	return nil
+}
+
+// ResetAllUsages resets the usage of a quota to zero for every user of your project.
+//
+// The quota usages of every user cached by the KobbleUsers client of the same Kobble client are invalidated.
+//
+//   - @param quotaName - The name of the quota to reset.
+func (k KobbleQuotas) ResetAllUsages(quotaName string) error {
+	err := k.config.Http.PostJson("/quotas/resetAllUsages", map[string]any{
+		"quotaName": quotaName,
+	}, nil, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+
+	if k.config.OnUsageReset != nil {
+		k.config.OnUsageReset("")
+	}
+	return nil
+}
+
+type ListUsersOverThresholdOptions struct {
+	Limit int `json:"limit,omitempty"`
+	Page  int `json:"page,omitempty"`
+}
+
+// ListUsersOverThreshold fetches the users whose usage of a quota reached a given share of their limit.
+//
+// Options:
+//   - Page: The page number to fetch. Defaults to 1.
+//   - Limit: The number of users to fetch per page. Defaults to 50.
+//   - @param quotaName - The name of the quota to check.
+//   - @param threshold - The share of the limit, between 0 and 1. For instance, 0.8 lists the users who used at least 80% of their quota.
+func (k KobbleQuotas) ListUsersOverThreshold(quotaName string, threshold float64, options *ListUsersOverThresholdOptions) (common.Pagination[UserUsage], error) {
+	params := utils.PageParams(0, 0)
+	if options != nil {
+		params = utils.PageParams(options.Page, options.Limit)
+	}
+	params["quotaName"] = quotaName
+	params["threshold"] = strconv.FormatFloat(threshold, 'f', -1, 64)
+
+	var result common.Pagination[UserUsage]
+	err := k.config.Http.GetJson("/quotas/listUsersOverThreshold", params, &result, http.StatusOK)
+	if err != nil {
+		return common.Pagination[UserUsage]{}, err
+	}
+
+	return result, nil
+}
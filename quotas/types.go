@@ -1,6 +1,9 @@
 package quotas
 
-import "github.com/kobble-io/go-admin/utils"
+import (
+	"github.com/kobble-io/go-admin/utils"
+	"time"
+)
 
 type Quotas struct {
 	ID    string `json:"id"`
@@ -8,6 +11,34 @@ type Quotas struct {
 	Limit int    `json:"limit"`
 }
 
+// Config is the configuration of the KobbleQuotas client.
+//
+//   - Http is the HTTP client used to make requests to the Kobble API.
+//   - OnUsageReset is called after a usage reset made through this client, with the ID of the user whose usage was reset,
+//     or an empty ID when the usage of every user was reset. The Kobble client uses it to invalidate the cached quota usages of KobbleUsers.
 type Config struct {
-	http *utils.HttpClient
+	Http         *utils.HttpClient
+	OnUsageReset func(userId string)
+}
+
+// AggregatedUsage is the usage of a quota summed over all the users of the project.
+//
+//   - QuotaName is the name of the quota.
+//   - TotalUsage is the sum of the usage of all users.
+//   - UsersCount is the number of users with this quota.
+//   - UsersAtLimit is the number of users with no remaining credit on this quota.
+type AggregatedUsage struct {
+	QuotaName    string `json:"quota_name"`
+	TotalUsage   int64  `json:"total_usage"`
+	UsersCount   int64  `json:"users_count"`
+	UsersAtLimit int64  `json:"users_at_limit"`
+}
+
+// UserUsage is the usage of a quota by a single user.
+type UserUsage struct {
+	UserID    string    `json:"user_id"`
+	Usage     int       `json:"usage"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
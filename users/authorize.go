@@ -0,0 +1,138 @@
+package users
+
+import (
+	"context"
+	"github.com/kobble-io/go-admin/permissions"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"sync"
+)
+
+// Authorize decides whether a user holds the permissions and has remaining credit on the quotas of the request.
+//
+// The decision is made by the Kobble API in a single round-trip when it exposes a decision endpoint.
+// Otherwise, the permissions and quotas of the user are fetched in parallel (or read from cache) and the decision is computed locally.
+// If the user is blocked, an error matching ErrUserBlocked is returned.
+//
+//   - @param ctx - The context of the request.
+//   - @param userId - The unique identifier for the user being authorized.
+//   - @param req - The permissions and quotas the user needs.
+func (k KobbleUsers) Authorize(ctx context.Context, userId string, req AuthorizeRequest) (Decision, error) {
+	if len(req.PermissionNames) == 0 && len(req.QuotaNames) == 0 {
+		return emptyDecision(), nil
+	}
+
+	if isEndpointAvailable(&k.endpoints.decisionUnsupportedUntil) {
+		decision, err := k.fetchDecision(ctx, userId, req)
+		if err == nil {
+			return decision, nil
+		}
+
+		if !utils.IsUnsupportedEndpoint(err) {
+			return Decision{}, wrapUserBlockedError(userId, err)
+		}
+		markEndpointUnsupported(&k.endpoints.decisionUnsupportedUntil)
+	}
+
+	return k.computeDecision(ctx, userId, req)
+}
+
+func (k KobbleUsers) fetchDecision(ctx context.Context, userId string, req AuthorizeRequest) (Decision, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = ModeAll
+	}
+
+	var result Decision
+	err := k.config.Http.PostJsonWithContext(ctx, "/users/authorize", map[string]any{
+		"userId":          userId,
+		"permissionNames": req.PermissionNames,
+		"quotaNames":      req.QuotaNames,
+		"mode":            mode,
+	}, &result, http.StatusOK)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	if result.MissingPermissions == nil {
+		result.MissingPermissions = []string{}
+	}
+	if result.ExhaustedQuotas == nil {
+		result.ExhaustedQuotas = []string{}
+	}
+	return result, nil
+}
+
+func (k KobbleUsers) computeDecision(ctx context.Context, userId string, req AuthorizeRequest) (Decision, error) {
+	var (
+		wg                  sync.WaitGroup
+		perms               []permissions.Permission
+		quotas              []QuotaUsage
+		permsErr, quotasErr error
+	)
+
+	if len(req.PermissionNames) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			perms, permsErr = k.listPermissions(ctx, userId, req.NoCache)
+		}()
+	}
+
+	if len(req.QuotaNames) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			quotas, quotasErr = k.listQuotas(ctx, userId, req.NoCache)
+		}()
+	}
+
+	wg.Wait()
+	if permsErr != nil {
+		return Decision{}, permsErr
+	}
+	if quotasErr != nil {
+		return Decision{}, quotasErr
+	}
+
+	return evaluateDecision(perms, quotas, req), nil
+}
+
+// emptyDecision is the decision for a request without any permission nor quota, which is never allowed.
+func emptyDecision() Decision {
+	return Decision{
+		MissingPermissions: []string{},
+		ExhaustedQuotas:    []string{},
+		Reason:             DecisionReasonEmptyRequest,
+	}
+}
+
+// evaluateDecision computes the decision for req given the permissions and quota usages of a user.
+func evaluateDecision(perms []permissions.Permission, quotas []QuotaUsage, req AuthorizeRequest) Decision {
+	decision := Decision{
+		Allowed:            true,
+		MissingPermissions: []string{},
+		ExhaustedQuotas:    []string{},
+		Reason:             DecisionReasonGranted,
+	}
+
+	if len(req.PermissionNames) > 0 {
+		permissionCheck := evaluatePermissions(perms, req.PermissionNames, req.Mode)
+		decision.MissingPermissions = permissionCheck.MissingPermissions
+		if !permissionCheck.Granted {
+			decision.Allowed = false
+			decision.Reason = DecisionReasonMissingPermissions
+		}
+	}
+
+	if len(req.QuotaNames) > 0 {
+		quotaCheck := evaluateQuotas(quotas, req.QuotaNames, req.Mode)
+		decision.ExhaustedQuotas = quotaCheck.ExhaustedQuotas
+		if !quotaCheck.Granted && decision.Allowed {
+			decision.Allowed = false
+			decision.Reason = DecisionReasonExhaustedQuotas
+		}
+	}
+
+	return decision
+}
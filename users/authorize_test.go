@@ -0,0 +1,66 @@
+package users
+
+import (
+	"context"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAuthorizeNotFound(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		wantErr         bool
+		wantUnsupported bool
+	}{
+		{
+			name:    "an unknown user is an error and keeps the decision endpoint",
+			body:    `{"message":"User not found","error":"Not Found","statusCode":404}`,
+			wantErr: true,
+		},
+		{
+			name:            "an unknown route falls back to a local decision",
+			body:            `{"message":"Cannot POST /users/authorize","error":"Not Found","statusCode":404}`,
+			wantUnsupported: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var listings atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/users/authorize":
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(tt.body))
+				case "/users/listPermissions":
+					listings.Add(1)
+					_, _ = w.Write([]byte(`[{"id":"p1","name":"read"}]`))
+				default:
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+			}))
+			defer server.Close()
+
+			k := NewKobbleUsers(Config{Http: utils.NewHttpClient(utils.HttpClientConfig{BaseURL: server.URL})})
+			defer k.Close()
+
+			decision, err := k.Authorize(context.Background(), "u1", AuthorizeRequest{PermissionNames: []string{"read"}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authorize() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !decision.Allowed {
+				t.Errorf("Authorize() = %+v, want allowed", decision)
+			}
+			if got := !isEndpointAvailable(&k.endpoints.decisionUnsupportedUntil); got != tt.wantUnsupported {
+				t.Errorf("decision endpoint unsupported = %v, want %v", got, tt.wantUnsupported)
+			}
+			if got := listings.Load() > 0; got != tt.wantUnsupported {
+				t.Errorf("fell back to listPermissions = %v, want %v", got, tt.wantUnsupported)
+			}
+		})
+	}
+}
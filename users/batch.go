@@ -0,0 +1,70 @@
+package users
+
+import (
+	"context"
+	"sync"
+)
+
+const defaultBatchConcurrency = 10
+
+// BatchIsAllowed checks whether each user of a list holds the permissions and has remaining credit on the quotas of the payload.
+//
+// Users are checked concurrently, up to the configured concurrency. A failure on one user does not stop the batch:
+// it is reported in the Err field of its result. Results are returned in the same order as userIds.
+// The permissions and quota usages fetched along the way are stored in cache, so that later checks on the same users are served locally.
+//
+//   - @param ctx - The context of the batch. When it is canceled, the users not yet checked are reported with its error.
+//   - @param userIds - The unique identifiers of the users to check.
+//   - @param payload - The payload containing the permission and quota names to check.
+func (k KobbleUsers) BatchIsAllowed(ctx context.Context, userIds []string, payload IsAllowedPayload, opts *BatchIsAllowedOptions) ([]BatchIsAllowedResult, error) {
+	req := AuthorizeRequest{
+		PermissionNames: payload.PermissionNames,
+		QuotaNames:      payload.QuotaNames,
+	}
+	concurrency := defaultBatchConcurrency
+	if opts != nil {
+		req.NoCache = opts.NoCache
+		req.Mode = opts.Mode
+		if opts.Concurrency > 0 {
+			concurrency = opts.Concurrency
+		}
+	}
+
+	results := make([]BatchIsAllowedResult, len(userIds))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, userId := range userIds {
+		results[i].UserID = userId
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *BatchIsAllowedResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if len(req.PermissionNames) == 0 && len(req.QuotaNames) == 0 {
+				result.Decision = emptyDecision()
+				return
+			}
+
+			decision, err := k.computeDecision(ctx, result.UserID, req)
+			if err != nil {
+				result.Err = err
+				return
+			}
+
+			result.Allowed = decision.Allowed
+			result.Decision = decision
+		}(&results[i])
+	}
+
+	wg.Wait()
+	return results, ctx.Err()
+}
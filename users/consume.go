@@ -0,0 +1,99 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+)
+
+// ConsumeQuota atomically checks that a user has enough remaining credit on a quota and increments its usage.
+//
+// Unlike calling HasRemainingQuota then IncrementQuotaUsage, two concurrent calls can never both consume the last remaining credit.
+// The check is made by the Kobble API when it supports it. Otherwise, the usage is incremented and rolled back
+// if it turns out to exceed the limit.
+//
+//   - @param ctx - The context of the request.
+//   - @param userId - The unique identifier for the user whose quota is being consumed.
+//   - @param quotaName - The name of the quota to consume.
+//   - @param amount - The amount to consume. Must be positive.
+//   - @returns QuotaUsage - The usage of the quota after consumption, or a *QuotaExceededError (matching ErrQuotaExceeded) when there is not enough remaining credit.
+func (k KobbleUsers) ConsumeQuota(ctx context.Context, userId string, quotaName string, amount int) (QuotaUsage, error) {
+	if amount <= 0 {
+		return QuotaUsage{}, fmt.Errorf("invalid amount %d: must be positive", amount)
+	}
+
+	if isEndpointAvailable(&k.endpoints.consumeUnsupportedUntil) {
+		usage, err := k.consumeQuotaOnServer(ctx, userId, quotaName, amount)
+		if err == nil || !utils.IsUnsupportedEndpoint(err) {
+			return usage, err
+		}
+		markEndpointUnsupported(&k.endpoints.consumeUnsupportedUntil)
+	}
+
+	return k.consumeQuotaConditionally(ctx, userId, quotaName, amount)
+}
+
+func (k KobbleUsers) consumeQuotaOnServer(ctx context.Context, userId string, quotaName string, amount int) (QuotaUsage, error) {
+	var result ApiQuota
+	err := k.config.Http.PostJsonWithContext(ctx, "/quotas/consumeUsage", map[string]any{
+		"userId":    userId,
+		"quotaName": quotaName,
+		"amount":    amount,
+	}, &result, http.StatusCreated)
+	var httpErr *utils.HttpError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusConflict {
+		var current ApiQuota
+		if json.Unmarshal([]byte(httpErr.Body), &current) == nil {
+			return QuotaUsage{}, newQuotaExceededError(k.transformApiQuota(current))
+		}
+	}
+
+	return k.changedQuotaUsage(ctx, userId, quotaName, result, err)
+}
+
+// consumeQuotaConditionally emulates an atomic consumption: the usage is incremented, then rolled back if
+// a concurrent consumer made it go over the limit.
+func (k KobbleUsers) consumeQuotaConditionally(ctx context.Context, userId string, quotaName string, amount int) (QuotaUsage, error) {
+	before, err := k.getFreshQuotaUsage(ctx, userId, quotaName)
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+
+	if before.Remaining != nil && *before.Remaining < amount {
+		return QuotaUsage{}, newQuotaExceededError(before)
+	}
+
+	after, err := k.incrementQuotaUsage(ctx, userId, quotaName, amount)
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+
+	if after.Remaining != nil && *after.Remaining < 0 {
+		rolledBack, err := k.incrementQuotaUsage(ctx, userId, quotaName, -amount)
+		if err != nil {
+			return QuotaUsage{}, fmt.Errorf("failed to roll back usage of quota %s: %w", quotaName, err)
+		}
+
+		return QuotaUsage{}, newQuotaExceededError(rolledBack)
+	}
+
+	return after, nil
+}
+
+func (k KobbleUsers) getFreshQuotaUsage(ctx context.Context, userId string, quotaName string) (QuotaUsage, error) {
+	quotas, err := k.listQuotas(ctx, userId, true)
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+
+	for _, quota := range quotas {
+		if quota.Name == quotaName {
+			return quota, nil
+		}
+	}
+
+	return QuotaUsage{}, fmt.Errorf("quota %s not found for user %s", quotaName, userId)
+}
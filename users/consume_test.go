@@ -0,0 +1,66 @@
+package users
+
+import (
+	"context"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConsumeQuotaNotFound(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		wantErr         bool
+		wantUnsupported bool
+	}{
+		{
+			name:    "an unknown user is an error and keeps the endpoint",
+			body:    `{"message":"User not found","error":"Not Found","statusCode":404}`,
+			wantErr: true,
+		},
+		{
+			name:            "an unknown route falls back to the conditional increment",
+			body:            `{"message":"Cannot POST /quotas/consumeUsage","error":"Not Found","statusCode":404}`,
+			wantUnsupported: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var increments atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/quotas/consumeUsage":
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(tt.body))
+				case "/users/listQuotas":
+					_, _ = w.Write([]byte(`{"quotas":[{"name":"credits","usage":1,"remaining":9,"limit":10}]}`))
+				case "/quotas/incrementUsage":
+					increments.Add(1)
+					w.WriteHeader(http.StatusCreated)
+					_, _ = w.Write([]byte(`{"name":"credits","usage":2,"remaining":8,"limit":10}`))
+				default:
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+			}))
+			defer server.Close()
+
+			k := NewKobbleUsers(Config{Http: utils.NewHttpClient(utils.HttpClientConfig{BaseURL: server.URL})})
+			defer k.Close()
+
+			_, err := k.ConsumeQuota(context.Background(), "u1", "credits", 1)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ConsumeQuota() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := !isEndpointAvailable(&k.endpoints.consumeUnsupportedUntil); got != tt.wantUnsupported {
+				t.Errorf("consume endpoint unsupported = %v, want %v", got, tt.wantUnsupported)
+			}
+			if got := increments.Load() > 0; got != tt.wantUnsupported {
+				t.Errorf("fell back to incrementUsage = %v, want %v", got, tt.wantUnsupported)
+			}
+		})
+	}
+}
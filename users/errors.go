@@ -0,0 +1,118 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kobble-io/go-admin/common"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrUserBlocked is returned when checking the permissions or quotas of a blocked user.
+var ErrUserBlocked = common.ErrUserBlocked
+
+// wrapUserBlockedError turns the error returned by the Kobble API for a blocked user into ErrUserBlocked.
+func wrapUserBlockedError(userId string, err error) error {
+	var httpErr *utils.HttpError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusForbidden && strings.Contains(httpErr.Body, common.UserBlockedErrorName) {
+		return fmt.Errorf("%w: %s", ErrUserBlocked, userId)
+	}
+	return err
+}
+
+// ErrQuotaExceeded is matched by errors.Is for every QuotaExceededError.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaExceededError is returned when consuming a quota would exceed its limit.
+//
+//   - QuotaName is the name of the exceeded quota.
+//   - Limit is the limit of the quota.
+//   - Usage is the current usage of the quota, which was left untouched.
+//   - ResetsAt is the time at which the usage of the quota resets.
+type QuotaExceededError struct {
+	*utils.ErrorBase
+	QuotaName string
+	Limit     int
+	Usage     int
+	ResetsAt  time.Time
+}
+
+func newQuotaExceededError(quota QuotaUsage) *QuotaExceededError {
+	limit := 0
+	if quota.Limit != nil {
+		limit = *quota.Limit
+	}
+
+	return &QuotaExceededError{
+		ErrorBase: utils.NewErrorBase(
+			"QUOTA_EXCEEDED",
+			fmt.Sprintf("Quota %s is exceeded (%d/%d), it resets at %s.", quota.Name, quota.Usage, limit, quota.ExpiresAt.Format(time.RFC3339)),
+			nil,
+		),
+		QuotaName: quota.Name,
+		Limit:     limit,
+		Usage:     quota.Usage,
+		ResetsAt:  quota.ExpiresAt,
+	}
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// ErrReservationSettled is returned when committing or releasing a Reservation that was already committed or released.
+var ErrReservationSettled = errors.New("reservation already settled")
+
+// ErrReservationExpired is returned when committing or releasing a Reservation that expired, and was or is being released automatically.
+var ErrReservationExpired = errors.New("reservation expired")
+
+// ErrInvalidMetadata is matched by errors.Is for every MetadataShapeError.
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// MetadataShapeError is returned when a user's metadata does not match the shape of the Go value it is decoded into or encoded from.
+//
+//   - Field is the path of the mismatched field (e.g. "billing.seats"), or empty for the whole document.
+//   - Expected is the Go type expected for the field.
+//   - Actual is the JSON type found in the metadata.
+type MetadataShapeError struct {
+	*utils.ErrorBase
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func newMetadataShapeError(field, expected, actual string, cause error) *MetadataShapeError {
+	message := fmt.Sprintf("Metadata is a %s, expected %s.", actual, expected)
+	if field != "" {
+		message = fmt.Sprintf("Metadata field %s is a %s, expected %s.", field, actual, expected)
+	}
+
+	return &MetadataShapeError{
+		ErrorBase: utils.NewErrorBase("INVALID_METADATA", message, cause),
+		Field:     field,
+		Expected:  expected,
+		Actual:    actual,
+	}
+}
+
+func (e *MetadataShapeError) Is(target error) bool {
+	return target == ErrInvalidMetadata
+}
+
+// ErrMetadataConflict is returned when a conditional metadata update is rejected because the metadata changed since it was read.
+var ErrMetadataConflict = errors.New("metadata revision conflict")
+
+// ErrConditionalUpdateUnsupported is returned by conditional metadata updates when the Kobble API does not version metadata,
+// so that the update cannot be guaranteed not to overwrite concurrent changes.
+var ErrConditionalUpdateUnsupported = errors.New("conditional metadata updates are not supported")
+
+// wrapMetadataConflictError turns the error returned by the Kobble API for a stale revision into ErrMetadataConflict.
+func wrapMetadataConflictError(userId string, revision int64, err error) error {
+	var httpErr *utils.HttpError
+	if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusConflict || httpErr.StatusCode == http.StatusPreconditionFailed) {
+		return fmt.Errorf("%w: user %s is no longer at revision %d", ErrMetadataConflict, userId, revision)
+	}
+	return err
+}
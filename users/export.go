@@ -0,0 +1,288 @@
+package users
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat is the format written by KobbleUsers.Export.
+type ExportFormat string
+
+const (
+	// ExportFormatCSV writes comma separated values, with metadata flattened to columns.
+	ExportFormatCSV ExportFormat = "csv"
+	// ExportFormatTSV writes tab separated values, with metadata flattened to columns.
+	ExportFormatTSV ExportFormat = "tsv"
+	// ExportFormatJSONL writes one JSON user object per line, with its full metadata.
+	ExportFormatJSONL ExportFormat = "jsonl"
+)
+
+var exportBaseColumns = []string{"id", "email", "name", "phone_number", "created_at", "is_verified", "is_blocked"}
+
+// ExportOptions is the configuration of an export.
+//
+//   - Metadata only exports the users matching this metadata, through FindByMetadata. Defaults to all users.
+//   - PageSize is the number of users fetched per page. Defaults to 50.
+//   - StartPage is the first page to export, to resume an interrupted export from its last checkpoint. Defaults to 1.
+//     The CSV/TSV header is only written when starting from the first page.
+//   - MetadataColumns are the flattened metadata keys exported as CSV/TSV columns (e.g. "plan" or "billing.seats").
+//     When empty, they are inferred from the users of the first page, and later pages must not hold other keys (see IgnoreUnmappedMetadata).
+//     They are required to resume a CSV/TSV export from a later page, and must match the columns of the header written before.
+//   - IgnoreUnmappedMetadata drops the metadata keys that are not in MetadataColumns. By default, a page holding
+//     such keys stops the export with an error matching ErrUnmappedMetadata, before the page is written, so that
+//     no metadata is silently lost. The export can then be resumed from that page with the missing columns.
+//   - OnProgress is called after each exported page. Its Page field is the checkpoint to resume from (as StartPage, plus one).
+type ExportOptions struct {
+	Metadata               map[string]any
+	PageSize               int
+	StartPage              int
+	MetadataColumns        []string
+	IgnoreUnmappedMetadata bool
+	OnProgress             func(progress ExportProgress)
+}
+
+// ErrUnmappedMetadata is returned by a CSV/TSV export when users hold metadata keys that are not exported as columns.
+var ErrUnmappedMetadata = errors.New("metadata keys not exported as columns")
+
+// ExportProgress reports the progress of an export.
+//
+//   - Page is the last page fully written.
+//   - Exported is the number of users written so far by this export.
+//   - Total is the total number of users to export, as reported by the Kobble API.
+type ExportProgress struct {
+	Page     int64
+	Exported int64
+	Total    int64
+}
+
+// Export writes all users, with their metadata, to a writer.
+//
+// Users are fetched page by page and written as soon as they are received, so exports of any size run in constant memory.
+// When the export is interrupted, the returned progress holds the last page written, to resume with StartPage.
+//
+//   - @param ctx - The context of the export.
+//   - @param writer - The destination of the export.
+//   - @param format - The format of the export, ExportFormatCSV, ExportFormatTSV or ExportFormatJSONL.
+func (k KobbleUsers) Export(ctx context.Context, writer io.Writer, format ExportFormat, opts *ExportOptions) (ExportProgress, error) {
+	options := ExportOptions{}
+	if opts != nil {
+		options = *opts
+	}
+
+	listOptions := &ListUsersOptions{
+		Page:            options.StartPage,
+		Limit:           options.PageSize,
+		IncludeMetadata: true,
+	}
+	it := k.IterateAll(listOptions)
+	if options.Metadata != nil {
+		it = k.IterateByMetadata(options.Metadata, listOptions)
+	}
+
+	var write func(users []User) error
+	var flush func() error
+	// finish is called once every page was written.
+	finish := func() error { return nil }
+	switch format {
+	case ExportFormatJSONL:
+		encoder := json.NewEncoder(writer)
+		write = func(users []User) error {
+			for _, user := range users {
+				if err := encoder.Encode(user); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		flush = func() error { return nil }
+
+	case ExportFormatCSV, ExportFormatTSV:
+		csvWriter := csv.NewWriter(writer)
+		if format == ExportFormatTSV {
+			csvWriter.Comma = '\t'
+		}
+		columns := options.MetadataColumns
+		pendingHeader := options.StartPage <= 1
+		writeHeader := func() error {
+			header := append([]string{}, exportBaseColumns...)
+			for _, column := range columns {
+				header = append(header, metadataFieldPrefix+column)
+			}
+			pendingHeader = false
+			return csvWriter.Write(header)
+		}
+		write = func(users []User) error {
+			if len(columns) == 0 && pendingHeader {
+				columns = inferMetadataColumns(users)
+			}
+			if !options.IgnoreUnmappedMetadata {
+				if unmapped := unmappedMetadataColumns(users, columns); len(unmapped) > 0 {
+					return fmt.Errorf("%w: %s", ErrUnmappedMetadata, strings.Join(unmapped, ", "))
+				}
+			}
+			if pendingHeader {
+				if err := writeHeader(); err != nil {
+					return err
+				}
+			}
+
+			for _, user := range users {
+				if err := csvWriter.Write(userToRecord(user, columns)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+		// An export without any user still gets its header.
+		finish = func() error {
+			if !pendingHeader {
+				return nil
+			}
+			if err := writeHeader(); err != nil {
+				return err
+			}
+			return flush()
+		}
+
+		// The header can be written right away when the columns are known.
+		if pendingHeader && len(columns) > 0 {
+			if err := writeHeader(); err != nil {
+				return ExportProgress{}, err
+			}
+			if err := flush(); err != nil {
+				return ExportProgress{}, err
+			}
+		}
+
+	default:
+		return ExportProgress{}, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	var progress ExportProgress
+	if options.StartPage > 1 {
+		progress.Page = int64(options.StartPage - 1)
+	}
+	for it.Next(ctx) {
+		page := it.Page()
+		if err := write(page.Data); err != nil {
+			return progress, err
+		}
+		if err := flush(); err != nil {
+			return progress, err
+		}
+
+		progress.Page = page.Page
+		progress.Exported += int64(len(page.Data))
+		progress.Total = page.Total
+		if options.OnProgress != nil {
+			options.OnProgress(progress)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return progress, err
+	}
+
+	return progress, finish()
+}
+
+// flattenMetadata flattens nested metadata objects into dot separated keys.
+func flattenMetadata(prefix string, metadata map[string]any, flat map[string]any) {
+	for key, value := range metadata {
+		if nested, ok := value.(map[string]any); ok {
+			flattenMetadata(prefix+key+".", nested, flat)
+			continue
+		}
+		flat[prefix+key] = value
+	}
+}
+
+func inferMetadataColumns(users []User) []string {
+	seen := map[string]bool{}
+	for _, user := range users {
+		flat := map[string]any{}
+		flattenMetadata("", user.Metadata, flat)
+		for key := range flat {
+			seen[key] = true
+		}
+	}
+
+	columns := make([]string, 0, len(seen))
+	for key := range seen {
+		columns = append(columns, key)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// unmappedMetadataColumns returns the flattened metadata keys of users missing from columns, sorted.
+func unmappedMetadataColumns(users []User, columns []string) []string {
+	mapped := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		mapped[column] = true
+	}
+
+	var unmapped []string
+	for _, column := range inferMetadataColumns(users) {
+		if !mapped[column] {
+			unmapped = append(unmapped, column)
+		}
+	}
+	return unmapped
+}
+
+func userToRecord(user User, metadataColumns []string) []string {
+	optional := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+
+	record := []string{
+		user.ID,
+		user.Email,
+		optional(user.Name),
+		optional(user.PhoneNumber),
+		user.CreatedAt.Format(time.RFC3339),
+		strconv.FormatBool(user.IsVerified),
+		strconv.FormatBool(user.IsBlocked),
+	}
+
+	flat := map[string]any{}
+	flattenMetadata("", user.Metadata, flat)
+	for _, column := range metadataColumns {
+		record = append(record, formatMetadataValue(flat[column]))
+	}
+	return record
+}
+
+func formatMetadataValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
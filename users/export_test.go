@@ -0,0 +1,83 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportByMetadataIncludesMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/findByMetadata" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			return
+		}
+
+		var payload struct {
+			IncludeMetadata bool `json:"includeMetadata"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+
+		user := map[string]any{"id": "u1", "email": "a@example.com", "created_at": "2024-01-01T00:00:00Z"}
+		if payload.IncludeMetadata {
+			user["metadata"] = map[string]any{"plan": "pro"}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []any{user}, "page": 1, "total": 1, "hasNext": false})
+	}))
+	defer server.Close()
+
+	k := NewKobbleUsers(Config{Http: utils.NewHttpClient(utils.HttpClientConfig{BaseURL: server.URL})})
+	defer k.Close()
+
+	var out bytes.Buffer
+	_, err := k.Export(context.Background(), &out, ExportFormatCSV, &ExportOptions{Metadata: map[string]any{"plan": "pro"}})
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], ",metadata.plan") || !strings.HasSuffix(lines[1], ",pro") {
+		t.Errorf("Export() wrote %q, want the metadata of the user", out.String())
+	}
+}
+
+func TestExportWithoutUsersWritesHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []any{}, "page": 1, "total": 0, "hasNext": false})
+	}))
+	defer server.Close()
+
+	k := NewKobbleUsers(Config{Http: utils.NewHttpClient(utils.HttpClientConfig{BaseURL: server.URL})})
+	defer k.Close()
+
+	base := strings.Join(exportBaseColumns, ",")
+	tests := []struct {
+		name    string
+		format  ExportFormat
+		options *ExportOptions
+		want    string
+	}{
+		{name: "csv", format: ExportFormatCSV, want: base + "\n"},
+		{name: "csv with metadata columns", format: ExportFormatCSV, options: &ExportOptions{MetadataColumns: []string{"plan"}}, want: base + ",metadata.plan\n"},
+		{name: "tsv", format: ExportFormatTSV, want: strings.Join(exportBaseColumns, "\t") + "\n"},
+		{name: "csv resumed", format: ExportFormatCSV, options: &ExportOptions{StartPage: 2, MetadataColumns: []string{"plan"}}, want: ""},
+		{name: "jsonl", format: ExportFormatJSONL, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if _, err := k.Export(context.Background(), &out, tt.format, tt.options); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("Export() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
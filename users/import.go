@@ -0,0 +1,449 @@
+package users
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kobble-io/go-admin/utils"
+	"golang.org/x/time/rate"
+	"io"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ImportFormat is the format of the rows read by KobbleUsers.Import.
+type ImportFormat string
+
+const (
+	// ImportFormatCSV reads comma separated values. The first line must be a header naming the columns.
+	ImportFormatCSV ImportFormat = "csv"
+	// ImportFormatJSONL reads one JSON object per line.
+	ImportFormatJSONL ImportFormat = "jsonl"
+)
+
+// ExistingUserPolicy defines what KobbleUsers.Import does with rows matching an existing user.
+type ExistingUserPolicy string
+
+const (
+	// ImportSkipExisting leaves existing users untouched. This is the default.
+	ImportSkipExisting ExistingUserPolicy = "skip"
+	// ImportUpdateExisting updates the name, phone number and metadata of existing users.
+	ImportUpdateExisting ExistingUserPolicy = "update"
+)
+
+// ImportStatus is the outcome of the import of a single row.
+type ImportStatus string
+
+const (
+	ImportStatusCreated ImportStatus = "created"
+	ImportStatusUpdated ImportStatus = "updated"
+	ImportStatusSkipped ImportStatus = "skipped"
+	ImportStatusValid   ImportStatus = "valid"
+	ImportStatusFailed  ImportStatus = "failed"
+)
+
+const (
+	defaultImportConcurrency = 4
+	metadataFieldPrefix      = "metadata."
+)
+
+// ImportOptions is the configuration of an import.
+//
+//   - ColumnMapping maps the columns (CSV) or keys (JSONL) of the source to user fields: "email", "name", "phone_number"
+//     or "metadata.<key>". Columns that are not mapped are matched by name, and ignored when they match no field.
+//     In JSONL, a "metadata" object is also read as metadata.
+//   - Concurrency is the number of users created at the same time. Defaults to 4.
+//   - RequestsPerSecond limits the rate of requests sent to Kobble. Zero means unlimited.
+//   - DryRun only parses and validates the rows, without creating nor updating any user.
+//   - OnExisting is what to do with rows matching an existing user (by email, then phone number). Defaults to ImportSkipExisting.
+//   - MarkEmailAsVerified marks the email of created users as verified.
+//   - OnRow is called with the result of each row as soon as it is known. Calls are serialized but not ordered.
+type ImportOptions struct {
+	ColumnMapping       map[string]string
+	Concurrency         int
+	RequestsPerSecond   float64
+	DryRun              bool
+	OnExisting          ExistingUserPolicy
+	MarkEmailAsVerified bool
+	OnRow               func(result ImportRowResult)
+}
+
+// ImportRowResult is the outcome of the import of a single row.
+//
+//   - Row is the 1-based number of the row in the source, not counting the CSV header.
+//   - UserID is the unique identifier of the created, updated or skipped user.
+//   - Err is the reason why the row failed, if any.
+type ImportRowResult struct {
+	Row    int
+	Status ImportStatus
+	UserID string
+	Err    error
+}
+
+// ImportReport summarizes an import. Rows holds the result of every row, ordered by row number.
+type ImportReport struct {
+	Total   int
+	Created int
+	Updated int
+	Skipped int
+	Valid   int
+	Failed  int
+	Rows    []ImportRowResult
+}
+
+type importRow struct {
+	number  int
+	payload CreateUserPayload
+	err     error
+}
+
+var e164Regexp = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// ValidateCreateUserPayload checks that a payload has a valid email and/or a phone number in E.164 format.
+func ValidateCreateUserPayload(payload CreateUserPayload) error {
+	if payload.Email == "" && payload.PhoneNumber == "" {
+		return errors.New("either an email or a phone number is required")
+	}
+
+	if payload.Email != "" {
+		address, err := mail.ParseAddress(payload.Email)
+		if err != nil || address.Address != payload.Email {
+			return fmt.Errorf("invalid email: %s", payload.Email)
+		}
+	}
+
+	if payload.PhoneNumber != "" && !e164Regexp.MatchString(payload.PhoneNumber) {
+		return fmt.Errorf("invalid phone number %s: must be in E.164 format (e.g. +14155552671)", payload.PhoneNumber)
+	}
+
+	return nil
+}
+
+// Import creates users in bulk from CSV or JSONL rows.
+//
+// Rows are streamed from the reader, validated (email syntax, E.164 phone number) and created with bounded concurrency.
+// A failing row does not stop the import: it is reported in the returned ImportReport. An error is only returned
+// when the source cannot be read anymore or when the context is canceled, along with the report of the rows processed so far.
+//
+//   - @param ctx - The context of the import.
+//   - @param reader - The source of the rows.
+//   - @param format - The format of the source, ImportFormatCSV or ImportFormatJSONL.
+func (k KobbleUsers) Import(ctx context.Context, reader io.Reader, format ImportFormat, opts *ImportOptions) (ImportReport, error) {
+	options := ImportOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = defaultImportConcurrency
+	}
+	if options.OnExisting == "" {
+		options.OnExisting = ImportSkipExisting
+	}
+
+	var limiter *rate.Limiter
+	if options.RequestsPerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(options.RequestsPerSecond), 1)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rows := make(chan importRow)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(rows)
+		readErr <- readImportRows(ctx, reader, format, options.ColumnMapping, rows)
+	}()
+
+	var (
+		mu     sync.Mutex
+		report ImportReport
+		wg     sync.WaitGroup
+	)
+	record := func(result ImportRowResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		report.Total++
+		switch result.Status {
+		case ImportStatusCreated:
+			report.Created++
+		case ImportStatusUpdated:
+			report.Updated++
+		case ImportStatusSkipped:
+			report.Skipped++
+		case ImportStatusValid:
+			report.Valid++
+		case ImportStatusFailed:
+			report.Failed++
+		}
+		report.Rows = append(report.Rows, result)
+
+		if options.OnRow != nil {
+			options.OnRow(result)
+		}
+	}
+
+	for i := 0; i < options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range rows {
+				record(k.importRow(ctx, row, options, limiter))
+			}
+		}()
+	}
+
+	wg.Wait()
+	err := <-readErr
+
+	sort.Slice(report.Rows, func(i, j int) bool {
+		return report.Rows[i].Row < report.Rows[j].Row
+	})
+	return report, err
+}
+
+func (k KobbleUsers) importRow(ctx context.Context, row importRow, options ImportOptions, limiter *rate.Limiter) ImportRowResult {
+	result := ImportRowResult{Row: row.number}
+	fail := func(err error) ImportRowResult {
+		result.Status = ImportStatusFailed
+		result.Err = err
+		return result
+	}
+
+	if row.err != nil {
+		return fail(row.err)
+	}
+
+	payload := row.payload
+	payload.MarkEmailAsVerified = payload.MarkEmailAsVerified || options.MarkEmailAsVerified
+	if err := ValidateCreateUserPayload(payload); err != nil {
+		return fail(err)
+	}
+
+	if options.DryRun {
+		result.Status = ImportStatusValid
+		return result
+	}
+
+	wait := func() error {
+		if limiter == nil {
+			return ctx.Err()
+		}
+		return limiter.Wait(ctx)
+	}
+
+	if err := wait(); err != nil {
+		return fail(err)
+	}
+	existing, err := k.findExistingUser(payload)
+	if err != nil {
+		return fail(err)
+	}
+
+	if existing != nil {
+		result.UserID = existing.ID
+		if options.OnExisting != ImportUpdateExisting {
+			result.Status = ImportStatusSkipped
+			return result
+		}
+
+		if err := wait(); err != nil {
+			return fail(err)
+		}
+		update := UpdateUserPayload{PhoneNumber: payload.PhoneNumber}
+		if payload.Name != "" {
+			update.Name = &payload.Name
+		}
+		if update.Name != nil || update.PhoneNumber != "" {
+			if _, err := k.Update(existing.ID, update); err != nil {
+				return fail(err)
+			}
+		}
+
+		if len(payload.Metadata) > 0 {
+			if err := wait(); err != nil {
+				return fail(err)
+			}
+			if _, err := k.PatchMetadata(existing.ID, payload.Metadata); err != nil {
+				return fail(err)
+			}
+		}
+
+		result.Status = ImportStatusUpdated
+		return result
+	}
+
+	if err := wait(); err != nil {
+		return fail(err)
+	}
+	user, err := k.Create(payload)
+	if err != nil {
+		return fail(err)
+	}
+
+	result.Status = ImportStatusCreated
+	result.UserID = user.ID
+	return result
+}
+
+// findExistingUser looks up a user by the email, then the phone number of a payload. It returns nil when there is none.
+func (k KobbleUsers) findExistingUser(payload CreateUserPayload) (*User, error) {
+	isNotFound := func(err error) bool {
+		var httpErr *utils.HttpError
+		return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound
+	}
+
+	if payload.Email != "" {
+		user, err := k.GetByEmail(payload.Email, nil)
+		if err == nil {
+			return user, nil
+		}
+		if !isNotFound(err) {
+			return nil, err
+		}
+	}
+
+	if payload.PhoneNumber != "" {
+		user, err := k.GetByPhoneNumber(payload.PhoneNumber, nil)
+		if err == nil {
+			return user, nil
+		}
+		if !isNotFound(err) {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func readImportRows(ctx context.Context, reader io.Reader, format ImportFormat, mapping map[string]string, rows chan<- importRow) error {
+	send := func(row importRow) error {
+		select {
+		case rows <- row:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	switch format {
+	case ImportFormatCSV:
+		csvReader := csv.NewReader(reader)
+		header, err := csvReader.Read()
+		if err != nil {
+			return fmt.Errorf("failed to read CSV header: %w", err)
+		}
+
+		for number := 1; ; number++ {
+			record, err := csvReader.Read()
+			if err == io.EOF {
+				return nil
+			}
+
+			row := importRow{number: number}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+				row.err = err
+			} else if err != nil {
+				return err
+			} else {
+				values := make(map[string]any, len(record))
+				for i, value := range record {
+					if value != "" {
+						values[header[i]] = value
+					}
+				}
+				row.payload, row.err = mapImportValues(values, mapping)
+			}
+
+			if err := send(row); err != nil {
+				return err
+			}
+		}
+
+	case ImportFormatJSONL:
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		number := 0
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			number++
+			row := importRow{number: number}
+			var values map[string]any
+			if err := json.Unmarshal([]byte(line), &values); err != nil {
+				row.err = fmt.Errorf("invalid JSON: %w", err)
+			} else {
+				row.payload, row.err = mapImportValues(values, mapping)
+			}
+
+			if err := send(row); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+
+	return fmt.Errorf("unsupported import format: %s", format)
+}
+
+// mapImportValues builds the payload of a user from the values of a row, keyed by column name.
+func mapImportValues(values map[string]any, mapping map[string]string) (CreateUserPayload, error) {
+	var payload CreateUserPayload
+	asString := func(field string, value any) (string, error) {
+		str, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("invalid %s: expected a string but got %T", field, value)
+		}
+		return strings.TrimSpace(str), nil
+	}
+
+	for column, value := range values {
+		field := column
+		if mapped, ok := mapping[column]; ok {
+			field = mapped
+		}
+
+		var err error
+		switch {
+		case field == "email":
+			payload.Email, err = asString(field, value)
+		case field == "name":
+			payload.Name, err = asString(field, value)
+		case field == "phone_number":
+			payload.PhoneNumber, err = asString(field, value)
+		case field == "metadata":
+			metadata, ok := value.(map[string]any)
+			if !ok {
+				return CreateUserPayload{}, fmt.Errorf("invalid metadata: expected an object but got %T", value)
+			}
+			for key, v := range metadata {
+				if payload.Metadata == nil {
+					payload.Metadata = make(map[string]any)
+				}
+				payload.Metadata[key] = v
+			}
+		case strings.HasPrefix(field, metadataFieldPrefix):
+			if payload.Metadata == nil {
+				payload.Metadata = make(map[string]any)
+			}
+			payload.Metadata[strings.TrimPrefix(field, metadataFieldPrefix)] = value
+		}
+		if err != nil {
+			return CreateUserPayload{}, err
+		}
+	}
+
+	return payload, nil
+}
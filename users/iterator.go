@@ -0,0 +1,106 @@
+package users
+
+import (
+	"context"
+	"github.com/kobble-io/go-admin/common"
+)
+
+// UserIterator walks the pages of a paginated listing of users.
+//
+//	it := k.Users.IterateAll(&users.ListUsersOptions{IncludeMetadata: true})
+//	for it.Next(ctx) {
+//		for _, user := range it.Page().Data {
+//			// ...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		// ...
+//	}
+type UserIterator struct {
+	fetch   func(ctx context.Context, page int) (common.Pagination[User], error)
+	next    int
+	current common.Pagination[User]
+	err     error
+	done    bool
+}
+
+func newUserIterator(startPage int, fetch func(ctx context.Context, page int) (common.Pagination[User], error)) *UserIterator {
+	if startPage < 1 {
+		startPage = 1
+	}
+	return &UserIterator{
+		fetch: fetch,
+		next:  startPage,
+	}
+}
+
+// Next fetches the next page. It returns false when there are no more pages or when an error occurred, see Err.
+func (it *UserIterator) Next(ctx context.Context) bool {
+	if it.done {
+		return false
+	}
+
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		it.done = true
+		return false
+	}
+
+	page, err := it.fetch(ctx, it.next)
+	if err != nil {
+		it.err = err
+		it.done = true
+		return false
+	}
+
+	if page.Page == 0 {
+		page.Page = int64(it.next)
+	}
+	it.current = page
+	it.next++
+	if !page.HasNext || len(page.Data) == 0 {
+		it.done = true
+	}
+
+	return len(page.Data) > 0
+}
+
+// Page returns the page fetched by the last call to Next.
+func (it *UserIterator) Page() common.Pagination[User] {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *UserIterator) Err() error {
+	return it.err
+}
+
+// IterateAll returns an iterator over the pages of ListAll.
+//
+// The Page option is the first page to fetch, which allows resuming an interrupted iteration.
+func (k KobbleUsers) IterateAll(options *ListUsersOptions) *UserIterator {
+	opts := ListUsersOptions{}
+	if options != nil {
+		opts = *options
+	}
+
+	return newUserIterator(opts.Page, func(ctx context.Context, page int) (common.Pagination[User], error) {
+		opts.Page = page
+		return k.ListAll(&opts)
+	})
+}
+
+// IterateByMetadata returns an iterator over the pages of FindByMetadata.
+//
+// The Page option is the first page to fetch, which allows resuming an interrupted iteration.
+func (k KobbleUsers) IterateByMetadata(metadata map[string]any, options *ListUsersOptions) *UserIterator {
+	opts := ListUsersOptions{}
+	if options != nil {
+		opts = *options
+	}
+
+	return newUserIterator(opts.Page, func(ctx context.Context, page int) (common.Pagination[User], error) {
+		opts.Page = page
+		return k.FindByMetadata(metadata, &opts)
+	})
+}
@@ -0,0 +1,229 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kobble-io/go-admin/utils"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// DecodeMetadata decodes the user's metadata into v, which must be a pointer to a struct or a map.
+//
+// Metadata fields without a matching Go field are ignored. A field whose JSON type does not match
+// the Go type returns a MetadataShapeError.
+func (u User) DecodeMetadata(v any) error {
+	return decodeMetadata(u.Metadata, v)
+}
+
+// GetMetadata fetches a user's metadata and decodes it into T.
+//
+//	type Billing struct {
+//		Plan  string `json:"plan"`
+//		Seats int    `json:"seats"`
+//	}
+//
+//	billing, err := users.GetMetadata[Billing](ctx, k.Users, userId)
+func GetMetadata[T any](ctx context.Context, k *KobbleUsers, userId string) (T, error) {
+	var value T
+
+	user, err := k.getById(ctx, userId, true)
+	if err != nil {
+		return value, err
+	}
+
+	err = user.DecodeMetadata(&value)
+	return value, err
+}
+
+// PatchMetadataTyped encodes value into metadata and merges it with the user's metadata.
+//
+// Value must encode to a JSON object, a MetadataShapeError is returned otherwise.
+// Fields tagged with omitempty are left untouched on the user when empty.
+// The returned value is decoded from the resulting metadata.
+func PatchMetadataTyped[T any](ctx context.Context, k *KobbleUsers, userId string, value T) (T, error) {
+	var result T
+
+	metadata, err := encodeMetadata(value)
+	if err != nil {
+		return result, err
+	}
+
+	metadata, err = k.patchMetadata(ctx, userId, metadata)
+	if err != nil {
+		return result, err
+	}
+
+	err = decodeMetadata(metadata, &result)
+	return result, err
+}
+
+func encodeMetadata(value any) (map[string]any, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata map[string]any
+	if err := json.Unmarshal(encoded, &metadata); err != nil || metadata == nil {
+		return nil, newMetadataShapeError("", "object", jsonTypeName(encoded), err)
+	}
+
+	return metadata, nil
+}
+
+func decodeMetadata(metadata map[string]any, v any) error {
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(encoded, v)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return newMetadataShapeError(typeErr.Field, typeErr.Type.String(), typeErr.Value, err)
+	}
+	var invalidErr *json.InvalidUnmarshalError
+	if errors.As(err, &invalidErr) {
+		return fmt.Errorf("%w: %s", ErrInvalidMetadata, invalidErr.Error())
+	}
+
+	return err
+}
+
+func jsonTypeName(encoded []byte) string {
+	encoded = bytes.TrimSpace(encoded)
+	if len(encoded) == 0 {
+		return "nothing"
+	}
+
+	switch encoded[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "bool"
+	case 'n':
+		return "null"
+	default:
+		return "number"
+	}
+}
+
+const (
+	maxModifyMetadataAttempts = 5
+	modifyMetadataRetryDelay  = 20 * time.Millisecond
+)
+
+// UpdateMetadataIf replaces a user's metadata, only if it is still at the given revision.
+//
+// The revision is read from User.MetadataRevision, or from the result of a previous update.
+// ErrMetadataConflict is returned when the metadata was changed in the meantime.
+// The current revision is checked before writing, so that ErrConditionalUpdateUnsupported is returned without
+// replacing the metadata when the Kobble API does not version metadata.
+//
+//   - @param userId - The unique identifier for the user.
+//   - @param revision - The revision the metadata is expected to be at.
+//   - @param metadata - The new metadata of the user.
+func (k KobbleUsers) UpdateMetadataIf(userId string, revision int64, metadata map[string]any) (VersionedMetadata, error) {
+	ctx := context.Background()
+
+	user, err := k.getById(ctx, userId, true)
+	if err != nil {
+		return VersionedMetadata{}, err
+	}
+	if user.MetadataRevision == nil {
+		return VersionedMetadata{}, fmt.Errorf("%w: no revision returned for user %s", ErrConditionalUpdateUnsupported, userId)
+	}
+	if *user.MetadataRevision != revision {
+		return VersionedMetadata{}, fmt.Errorf("%w: user %s is at revision %d, not %d", ErrMetadataConflict, userId, *user.MetadataRevision, revision)
+	}
+
+	return k.updateMetadataIf(ctx, userId, revision, metadata)
+}
+
+// updateMetadataIf replaces a user's metadata if it is still at the given revision, on a Kobble API known to version metadata.
+// ErrConditionalUpdateUnsupported is returned when the response shows that the revision was not checked after all:
+// the metadata was then replaced regardless of concurrent changes.
+func (k KobbleUsers) updateMetadataIf(ctx context.Context, userId string, revision int64, metadata map[string]any) (VersionedMetadata, error) {
+	var result VersionedMetadata
+	err := k.config.Http.PostJsonWithContext(ctx, "/users/updateMetadata", map[string]any{
+		"userId":           userId,
+		"metadata":         metadata,
+		"expectedRevision": revision,
+	}, &result, http.StatusCreated)
+	if errors.Is(err, utils.ErrEmptyResponse) {
+		return VersionedMetadata{Metadata: metadata}, fmt.Errorf("%w: no revision returned by the update of user %s", ErrConditionalUpdateUnsupported, userId)
+	}
+	if err != nil {
+		return VersionedMetadata{}, wrapMetadataConflictError(userId, revision, err)
+	}
+
+	if result.Metadata == nil {
+		result.Metadata = metadata
+	}
+	if result.Revision <= revision {
+		return result, fmt.Errorf("%w: the revision of user %s was not incremented by the update", ErrConditionalUpdateUnsupported, userId)
+	}
+	return result, nil
+}
+
+// ModifyMetadata applies fn to the current metadata of a user and saves the result with UpdateMetadataIf.
+//
+// When the metadata is changed concurrently, it is fetched again and fn is applied again, up to 5 times.
+// Since fn can run several times, it should have no side effect other than modifying the metadata it receives.
+// An error returned by fn aborts the modification and is returned as is.
+// ErrConditionalUpdateUnsupported is returned, without calling fn, when the Kobble API does not version metadata.
+//
+//   - @param userId - The unique identifier for the user.
+//   - @param fn - The function modifying the metadata in place.
+func (k KobbleUsers) ModifyMetadata(userId string, fn func(metadata map[string]any) error) (VersionedMetadata, error) {
+	return k.modifyMetadata(context.Background(), userId, fn)
+}
+
+func (k KobbleUsers) modifyMetadata(ctx context.Context, userId string, fn func(metadata map[string]any) error) (VersionedMetadata, error) {
+	var err error
+	for attempt := 0; attempt < maxModifyMetadataAttempts; attempt++ {
+		if attempt > 0 {
+			delay := modifyMetadataRetryDelay*time.Duration(attempt) + time.Duration(rand.Int63n(int64(modifyMetadataRetryDelay)))
+			select {
+			case <-ctx.Done():
+				return VersionedMetadata{}, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		var user *User
+		user, err = k.getById(ctx, userId, true)
+		if err != nil {
+			return VersionedMetadata{}, err
+		}
+
+		if user.MetadataRevision == nil {
+			return VersionedMetadata{}, fmt.Errorf("%w: no revision returned for user %s", ErrConditionalUpdateUnsupported, userId)
+		}
+
+		if err := fn(user.Metadata); err != nil {
+			return VersionedMetadata{}, err
+		}
+
+		var result VersionedMetadata
+		result, err = k.updateMetadataIf(ctx, userId, *user.MetadataRevision, user.Metadata)
+		if !errors.Is(err, ErrMetadataConflict) {
+			return result, err
+		}
+	}
+
+	return VersionedMetadata{}, err
+}
@@ -0,0 +1,419 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchOp is the operation of a JSON Patch (RFC 6902) operation.
+type PatchOp string
+
+const (
+	PatchOpAdd     PatchOp = "add"
+	PatchOpRemove  PatchOp = "remove"
+	PatchOpReplace PatchOp = "replace"
+	PatchOpMove    PatchOp = "move"
+	PatchOpCopy    PatchOp = "copy"
+	PatchOpTest    PatchOp = "test"
+	// PatchOpIncrement adds Value to the number at Path. A missing field counts as zero.
+	// It is an extension to RFC 6902.
+	PatchOpIncrement PatchOp = "increment"
+)
+
+// PatchOperation is a JSON Patch (RFC 6902) operation.
+//
+//   - Path and From are JSON Pointers (RFC 6901), e.g. "/billing/seats". Use "/tags/-" to append to an array.
+//   - Value is the value to add, replace or test, or the amount to increment.
+//
+// Unlike RFC 6902, add and increment create the missing intermediate objects of their path,
+// or an array when appending to a missing field (e.g. "/tags/-").
+type PatchOperation struct {
+	Op    PatchOp `json:"op"`
+	Path  string  `json:"path"`
+	From  string  `json:"from,omitempty"`
+	Value any     `json:"value"`
+}
+
+// ErrInvalidPatch is returned when a metadata patch cannot be applied to the metadata of a user.
+var ErrInvalidPatch = errors.New("invalid metadata patch")
+
+// ErrPatchTestFailed is returned when a test operation of a metadata patch does not match the metadata of a user.
+var ErrPatchTestFailed = errors.New("metadata patch test failed")
+
+// SetMetadataField returns an operation setting the field at a dotted path (e.g. "billing.seats").
+func SetMetadataField(path string, value any) PatchOperation {
+	return PatchOperation{Op: PatchOpAdd, Path: metadataPointer(path), Value: value}
+}
+
+// RemoveMetadataField returns an operation removing the field at a dotted path (e.g. "billing.seats").
+func RemoveMetadataField(path string) PatchOperation {
+	return PatchOperation{Op: PatchOpRemove, Path: metadataPointer(path)}
+}
+
+// AppendMetadataField returns an operation appending a value to the array at a dotted path (e.g. "tags").
+func AppendMetadataField(path string, value any) PatchOperation {
+	return PatchOperation{Op: PatchOpAdd, Path: metadataPointer(path) + "/-", Value: value}
+}
+
+// IncrementMetadataField returns an operation adding delta to the number at a dotted path (e.g. "billing.seats").
+func IncrementMetadataField(path string, delta float64) PatchOperation {
+	return PatchOperation{Op: PatchOpIncrement, Path: metadataPointer(path), Value: delta}
+}
+
+// JsonPatchMetadata applies a JSON Patch (RFC 6902) to a user's metadata and returns the resulting metadata.
+//
+// The patch is applied atomically: it is applied with ModifyMetadata, so concurrent changes are never overwritten,
+// and the metadata is left untouched when any operation fails.
+// It fails with ErrConditionalUpdateUnsupported when the Kobble API does not version metadata.
+//
+//	result, err := k.Users.JsonPatchMetadata(userId, []users.PatchOperation{
+//		users.SetMetadataField("billing.plan", "pro"),
+//		users.IncrementMetadataField("billing.seats", 1),
+//		users.AppendMetadataField("tags", "upgraded"),
+//	})
+func (k KobbleUsers) JsonPatchMetadata(userId string, operations []PatchOperation) (VersionedMetadata, error) {
+	return k.modifyMetadata(context.Background(), userId, func(metadata map[string]any) error {
+		result, err := applyJsonPatch(metadata, operations)
+		if err != nil {
+			return err
+		}
+
+		clear(metadata)
+		maps.Copy(metadata, result)
+		return nil
+	})
+}
+
+// MergePatchMetadata applies a JSON Merge Patch (RFC 7396) to a user's metadata and returns the resulting metadata.
+//
+// Objects are merged recursively and null values remove the matching fields, e.g. {"billing": {"seats": 3, "trial": null}}.
+// Like JsonPatchMetadata, the patch is applied with ModifyMetadata.
+func (k KobbleUsers) MergePatchMetadata(userId string, patch map[string]any) (VersionedMetadata, error) {
+	normalized, err := normalizeJson(patch)
+	if err != nil {
+		return VersionedMetadata{}, err
+	}
+
+	return k.modifyMetadata(context.Background(), userId, func(metadata map[string]any) error {
+		applyMergePatch(metadata, normalized)
+		return nil
+	})
+}
+
+// metadataPointer turns a dotted metadata path into a JSON Pointer.
+func metadataPointer(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	replacer := strings.NewReplacer("~", "~0", "/", "~1")
+	var pointer strings.Builder
+	for _, token := range strings.Split(path, ".") {
+		pointer.WriteString("/")
+		pointer.WriteString(replacer.Replace(token))
+	}
+	return pointer.String()
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// normalizeJson round-trips a value through JSON, so it only holds maps, slices, strings, float64, bools and nil.
+func normalizeJson(value any) (any, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized any
+	err = json.Unmarshal(encoded, &normalized)
+	return normalized, err
+}
+
+func applyJsonPatch(metadata map[string]any, operations []PatchOperation) (map[string]any, error) {
+	doc, err := normalizeJson(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, operation := range operations {
+		doc, err = applyPatchOperation(doc, operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+
+	result, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata must remain an object", ErrInvalidPatch)
+	}
+	return result, nil
+}
+
+func applyPatchOperation(doc any, operation PatchOperation) (any, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := normalizeJson(operation.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case PatchOpAdd:
+		return patchAdd(doc, path, value)
+
+	case PatchOpRemove:
+		doc, _, err = patchRemove(doc, path)
+		return doc, err
+
+	case PatchOpReplace:
+		if _, err := patchGet(doc, path); err != nil {
+			return nil, err
+		}
+		doc, _, err = patchRemove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, value)
+
+	case PatchOpMove, PatchOpCopy:
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var moved any
+		if operation.Op == PatchOpMove {
+			doc, moved, err = patchRemove(doc, from)
+		} else {
+			moved, err = patchGet(doc, from)
+			if err == nil {
+				moved, err = normalizeJson(moved)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, moved)
+
+	case PatchOpTest:
+		current, err := patchGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrPatchTestFailed
+		}
+		return doc, nil
+
+	case PatchOpIncrement:
+		delta, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%w: increment value must be a number", ErrInvalidPatch)
+		}
+
+		current, err := patchGet(doc, path)
+		if err != nil {
+			current = float64(0)
+		}
+		number, ok := current.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%w: field is not a number", ErrInvalidPatch)
+		}
+		if err == nil {
+			if doc, _, err = patchRemove(doc, path); err != nil {
+				return nil, err
+			}
+		}
+		return patchAdd(doc, path, number+delta)
+
+	default:
+		return nil, fmt.Errorf("%w: unsupported operation %q", ErrInvalidPatch, operation.Op)
+	}
+}
+
+// patchAt walks to the parent of the location pointed by path and applies fn to it.
+// When create is true, the missing intermediate objects are created.
+func patchAt(node any, path []string, create bool, fn func(parent any, key string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	key := path[0]
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[key]
+		if !ok {
+			if !create {
+				return nil, fmt.Errorf("%w: field %s does not exist", ErrInvalidPatch, key)
+			}
+			// Appending to a missing field creates an array, any other missing field an object.
+			child = map[string]any{}
+			if path[1] == "-" {
+				child = []any{}
+			}
+		}
+
+		updated, err := patchAt(child, path[1:], create, fn)
+		if err != nil {
+			return nil, err
+		}
+		n[key] = updated
+		return n, nil
+
+	case []any:
+		index, err := arrayIndex(key, len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := patchAt(n[index], path[1:], create, fn)
+		if err != nil {
+			return nil, err
+		}
+		n[index] = updated
+		return n, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s is not inside an object or an array", ErrInvalidPatch, key)
+	}
+}
+
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %s", ErrInvalidPatch, token)
+	}
+	return index, nil
+}
+
+func patchGet(doc any, path []string) (any, error) {
+	node := doc
+	for _, key := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[key]
+			if !ok {
+				return nil, fmt.Errorf("%w: field %s does not exist", ErrInvalidPatch, key)
+			}
+			node = child
+
+		case []any:
+			index, err := arrayIndex(key, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[index]
+
+		default:
+			return nil, fmt.Errorf("%w: %s is not inside an object or an array", ErrInvalidPatch, key)
+		}
+	}
+	return node, nil
+}
+
+func patchAdd(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return patchAt(doc, path, true, func(parent any, key string) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			p[key] = value
+			return p, nil
+
+		case []any:
+			if key == "-" {
+				return append(p, value), nil
+			}
+			index, err := arrayIndex(key, len(p))
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[index+1:], p[index:])
+			p[index] = value
+			return p, nil
+
+		default:
+			return nil, fmt.Errorf("%w: %s is not inside an object or an array", ErrInvalidPatch, key)
+		}
+	})
+}
+
+func patchRemove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole metadata", ErrInvalidPatch)
+	}
+
+	var removed any
+	doc, err := patchAt(doc, path, false, func(parent any, key string) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			value, ok := p[key]
+			if !ok {
+				return nil, fmt.Errorf("%w: field %s does not exist", ErrInvalidPatch, key)
+			}
+			removed = value
+			delete(p, key)
+			return p, nil
+
+		case []any:
+			index, err := arrayIndex(key, len(p)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = p[index]
+			return append(p[:index], p[index+1:]...), nil
+
+		default:
+			return nil, fmt.Errorf("%w: %s is not inside an object or an array", ErrInvalidPatch, key)
+		}
+	})
+	return doc, removed, err
+}
+
+// applyMergePatch applies a JSON Merge Patch (RFC 7396) to target and returns the result.
+// When both are objects, target is modified in place.
+func applyMergePatch(target any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = applyMergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,78 @@
+package users
+
+import (
+	"errors"
+	"github.com/kobble-io/go-admin/internal/testutil"
+	"net/http"
+	"testing"
+)
+
+func TestUpdateMetadataIf(t *testing.T) {
+	revision := int64(3)
+
+	tests := []struct {
+		name        string
+		user        ApiUser
+		update      http.HandlerFunc
+		wantErr     error
+		wantUpdates int
+	}{
+		{
+			name: "updated",
+			user: ApiUser{ID: "u1", MetadataRevision: &revision},
+			update: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"metadata":{"plan":"pro"},"revision":4}`))
+			},
+			wantUpdates: 1,
+		},
+		{
+			name:    "a server that does not version metadata is detected before writing",
+			user:    ApiUser{ID: "u1"},
+			wantErr: ErrConditionalUpdateUnsupported,
+		},
+		{
+			name:    "a stale revision is a conflict detected before writing",
+			user:    ApiUser{ID: "u1", MetadataRevision: func() *int64 { r := int64(4); return &r }()},
+			wantErr: ErrMetadataConflict,
+		},
+		{
+			name: "an empty response means the revision was not checked",
+			user: ApiUser{ID: "u1", MetadataRevision: &revision},
+			update: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			},
+			wantErr:     ErrConditionalUpdateUnsupported,
+			wantUpdates: 1,
+		},
+		{
+			name: "a conflict answered by the Kobble API",
+			user: ApiUser{ID: "u1", MetadataRevision: &revision},
+			update: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusConflict)
+			},
+			wantErr:     ErrMetadataConflict,
+			wantUpdates: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := map[string]any{"/users/findById": tt.user}
+			if tt.update != nil {
+				responses["/users/updateMetadata"] = tt.update
+			}
+			server := testutil.NewServer(t, responses)
+			k := NewKobbleUsers(Config{Http: server.Http()})
+			defer k.Close()
+
+			_, err := k.UpdateMetadataIf("u1", revision, map[string]any{"plan": "pro"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("UpdateMetadataIf() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := server.Calls("/users/updateMetadata"); got != tt.wantUpdates {
+				t.Errorf("calls to /users/updateMetadata = %d, want %d", got, tt.wantUpdates)
+			}
+		})
+	}
+}
@@ -0,0 +1,547 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"github.com/kobble-io/go-admin/common"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QueryOperator is the comparison applied by a QueryCondition.
+type QueryOperator string
+
+const (
+	// Eq matches fields equal to the value.
+	Eq QueryOperator = "eq"
+	// Ne matches fields different from the value.
+	Ne QueryOperator = "ne"
+	// Gt matches fields greater than the value. Numbers, strings and times can be compared.
+	Gt QueryOperator = "gt"
+	// Gte matches fields greater than or equal to the value.
+	Gte QueryOperator = "gte"
+	// Lt matches fields lower than the value.
+	Lt QueryOperator = "lt"
+	// Lte matches fields lower than or equal to the value.
+	Lte QueryOperator = "lte"
+	// In matches fields equal to one of the values of a slice.
+	In QueryOperator = "in"
+	// Contains matches strings containing the value, or arrays containing an element equal to the value.
+	Contains QueryOperator = "contains"
+	// Exists matches fields that are set when the value is true, and unset when it is false.
+	Exists QueryOperator = "exists"
+)
+
+// SortDirection is the direction of a QueryOrder.
+type SortDirection string
+
+const (
+	Asc  SortDirection = "asc"
+	Desc SortDirection = "desc"
+)
+
+// QueryCondition is a condition of a UserQuery.
+//
+//   - Field is a user field (id, email, name, phone_number, created_at, is_verified or is_blocked)
+//     or a dotted metadata path (e.g. "metadata.billing.plan").
+type QueryCondition struct {
+	Field    string        `json:"field"`
+	Operator QueryOperator `json:"operator"`
+	Value    any           `json:"value"`
+}
+
+// QueryOrder is a sort criterion of a UserQuery.
+type QueryOrder struct {
+	Field     string        `json:"field"`
+	Direction SortDirection `json:"direction"`
+}
+
+// UserQuery is a search on the users of your Kobble instance, run with KobbleUsers.Search.
+//
+// All its conditions must match.
+//
+//	query := users.Query().
+//		Where("metadata.plan", users.Eq, "pro").
+//		And("created_at", users.Gt, since).
+//		Verified(true).
+//		OrderBy("created_at", users.Desc)
+type UserQuery struct {
+	conditions []QueryCondition
+	orders     []QueryOrder
+}
+
+// Query starts a new UserQuery matching every user.
+func Query() *UserQuery {
+	return &UserQuery{}
+}
+
+// Where adds a condition to the query.
+func (q *UserQuery) Where(field string, operator QueryOperator, value any) *UserQuery {
+	q.conditions = append(q.conditions, QueryCondition{Field: field, Operator: operator, Value: value})
+	return q
+}
+
+// And adds a condition to the query. It is an alias of Where.
+func (q *UserQuery) And(field string, operator QueryOperator, value any) *UserQuery {
+	return q.Where(field, operator, value)
+}
+
+// Verified only matches users whose email is verified, or not verified.
+func (q *UserQuery) Verified(verified bool) *UserQuery {
+	return q.Where("is_verified", Eq, verified)
+}
+
+// Blocked only matches users who are blocked, or not blocked.
+func (q *UserQuery) Blocked(blocked bool) *UserQuery {
+	return q.Where("is_blocked", Eq, blocked)
+}
+
+// OrderBy sorts the results by a field. Later calls break the ties of earlier ones.
+func (q *UserQuery) OrderBy(field string, direction SortDirection) *UserQuery {
+	q.orders = append(q.orders, QueryOrder{Field: field, Direction: direction})
+	return q
+}
+
+// Conditions returns the conditions of the query.
+func (q *UserQuery) Conditions() []QueryCondition {
+	return q.conditions
+}
+
+// Orders returns the sort criteria of the query.
+func (q *UserQuery) Orders() []QueryOrder {
+	return q.orders
+}
+
+// Search returns an iterator over the users matching a query.
+//
+// The query runs on the Kobble API when it supports searching. Otherwise, the users are listed and filtered
+// client-side, page by page. Sorting client-side requires every matching user: when the query has an OrderBy
+// and the Kobble API does not support searching, all the matching users are loaded in memory on the first page.
+// The backend used for the first page is used for the whole iteration.
+//
+// When filtering client-side without OrderBy, the Total of each page is the number of matching users found so far,
+// and only the last page holds the actual total.
+//
+// Options:
+//   - Page: The first page to fetch. Defaults to 1.
+//   - Limit: The number of users to fetch per page. Defaults to 50.
+//   - IncludeMetadata: Whether to include the user's metadata in the response. Defaults to false.
+func (k KobbleUsers) Search(query *UserQuery, options *ListUsersOptions) *UserIterator {
+	if query == nil {
+		query = Query()
+	}
+
+	opts := ListUsersOptions{}
+	if options != nil {
+		opts = *options
+	}
+	limit := 50
+	if opts.Limit > 0 {
+		limit = opts.Limit
+	}
+
+	decided := false
+	var fetch func(ctx context.Context, page int) (common.Pagination[User], error)
+	return newUserIterator(opts.Page, func(ctx context.Context, page int) (common.Pagination[User], error) {
+		if decided {
+			return fetch(ctx, page)
+		}
+
+		if isEndpointAvailable(&k.endpoints.searchUnsupportedUntil) {
+			result, err := k.searchOnServer(ctx, query, page, limit, opts.IncludeMetadata)
+			if !utils.IsUnsupportedEndpoint(err) {
+				if err == nil {
+					decided = true
+					fetch = func(ctx context.Context, page int) (common.Pagination[User], error) {
+						return k.searchOnServer(ctx, query, page, limit, opts.IncludeMetadata)
+					}
+				}
+				return result, err
+			}
+			markEndpointUnsupported(&k.endpoints.searchUnsupportedUntil)
+		}
+
+		decided = true
+		if len(query.orders) > 0 {
+			fetch = k.sortedLocalSearch(query, limit, opts.IncludeMetadata)
+		} else {
+			fetch = k.streamedLocalSearch(query, page, limit, opts.IncludeMetadata)
+		}
+		return fetch(ctx, page)
+	})
+}
+
+func (k KobbleUsers) searchOnServer(ctx context.Context, query *UserQuery, page, limit int, includeMetadata bool) (common.Pagination[User], error) {
+	conditions := query.conditions
+	if conditions == nil {
+		conditions = []QueryCondition{}
+	}
+	orders := query.orders
+	if orders == nil {
+		orders = []QueryOrder{}
+	}
+
+	var result common.Pagination[User]
+	err := k.config.Http.PostJsonWithContext(ctx, "/users/search", map[string]any{
+		"conditions":      conditions,
+		"orderBy":         orders,
+		"page":            page,
+		"limit":           limit,
+		"includeMetadata": includeMetadata,
+	}, &result, http.StatusOK)
+	if err != nil {
+		return common.Pagination[User]{}, err
+	}
+
+	return result, nil
+}
+
+// localSearchCandidates returns an iterator over the users that may match a query, with their metadata.
+// Top-level metadata equalities are delegated to FindByMetadata.
+func (k KobbleUsers) localSearchCandidates(query *UserQuery) *UserIterator {
+	metadata := map[string]any{}
+	for _, condition := range query.conditions {
+		path, ok := strings.CutPrefix(condition.Field, metadataFieldPrefix)
+		if ok && condition.Operator == Eq && !strings.Contains(path, ".") {
+			metadata[path] = condition.Value
+		}
+	}
+
+	listOptions := &ListUsersOptions{Limit: 100, IncludeMetadata: true}
+	if len(metadata) > 0 {
+		return k.IterateByMetadata(metadata, listOptions)
+	}
+	return k.IterateAll(listOptions)
+}
+
+// streamedLocalSearch filters the users client-side, one page at a time. Pages must be fetched in order from firstPage.
+func (k KobbleUsers) streamedLocalSearch(query *UserQuery, firstPage, limit int, includeMetadata bool) func(ctx context.Context, page int) (common.Pagination[User], error) {
+	it := k.localSearchCandidates(query)
+	skip := max(firstPage-1, 0) * limit
+	found := int64(skip)
+	exhausted := false
+	var buffer []User
+
+	return func(ctx context.Context, page int) (common.Pagination[User], error) {
+		// Look one user ahead, to know whether there is a next page.
+		for len(buffer) <= limit && !exhausted {
+			if !it.Next(ctx) {
+				if err := it.Err(); err != nil {
+					return common.Pagination[User]{}, err
+				}
+				exhausted = true
+				break
+			}
+
+			for _, user := range it.Page().Data {
+				if !query.matches(user) {
+					continue
+				}
+				if skip > 0 {
+					skip--
+					continue
+				}
+				buffer = append(buffer, user)
+			}
+		}
+
+		count := min(limit, len(buffer))
+		data := make([]User, 0, count)
+		for _, user := range buffer[:count] {
+			data = append(data, withMetadata(user, includeMetadata))
+		}
+		buffer = buffer[count:]
+		found += int64(count)
+
+		return common.Pagination[User]{
+			Total:   found + int64(len(buffer)),
+			Count:   int64(len(data)),
+			Page:    int64(page),
+			Data:    data,
+			HasNext: len(buffer) > 0,
+		}, nil
+	}
+}
+
+// sortedLocalSearch loads every user matching a query and sorts them client-side, then paginates them.
+func (k KobbleUsers) sortedLocalSearch(query *UserQuery, limit int, includeMetadata bool) func(ctx context.Context, page int) (common.Pagination[User], error) {
+	var matches []User
+	loaded := false
+
+	return func(ctx context.Context, page int) (common.Pagination[User], error) {
+		if !loaded {
+			it := k.localSearchCandidates(query)
+			for it.Next(ctx) {
+				for _, user := range it.Page().Data {
+					if query.matches(user) {
+						matches = append(matches, user)
+					}
+				}
+			}
+			if err := it.Err(); err != nil {
+				return common.Pagination[User]{}, err
+			}
+
+			sort.SliceStable(matches, func(i, j int) bool {
+				for _, order := range query.orders {
+					cmp := compareOrder(queryField(matches[i], order.Field), queryField(matches[j], order.Field))
+					if cmp == 0 {
+						continue
+					}
+					if order.Direction == Desc {
+						return cmp > 0
+					}
+					return cmp < 0
+				}
+				return false
+			})
+			loaded = true
+		}
+
+		return paginateUsers(matches, page, limit, includeMetadata), nil
+	}
+}
+
+func withMetadata(user User, includeMetadata bool) User {
+	if !includeMetadata {
+		user.Metadata = map[string]any{}
+	}
+	return user
+}
+
+func paginateUsers(users []User, page, limit int, includeMetadata bool) common.Pagination[User] {
+	if page < 1 {
+		page = 1
+	}
+
+	start := min((page-1)*limit, len(users))
+	end := min(start+limit, len(users))
+	data := make([]User, 0, end-start)
+	for _, user := range users[start:end] {
+		data = append(data, withMetadata(user, includeMetadata))
+	}
+
+	return common.Pagination[User]{
+		Total:   int64(len(users)),
+		Count:   int64(len(data)),
+		Page:    int64(page),
+		Data:    data,
+		HasNext: end < len(users),
+	}
+}
+
+func (q *UserQuery) matches(user User) bool {
+	for _, condition := range q.conditions {
+		if !condition.matches(queryField(user, condition.Field)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c QueryCondition) matches(field any) bool {
+	switch c.Operator {
+	case Eq:
+		return queryEqual(field, c.Value)
+	case Ne:
+		return !queryEqual(field, c.Value)
+	case Gt, Gte, Lt, Lte:
+		cmp, ok := queryCompare(field, c.Value)
+		if !ok {
+			return false
+		}
+		switch c.Operator {
+		case Gt:
+			return cmp > 0
+		case Gte:
+			return cmp >= 0
+		case Lt:
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	case In:
+		values := reflect.ValueOf(c.Value)
+		if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+			return false
+		}
+		for i := 0; i < values.Len(); i++ {
+			if queryEqual(field, values.Index(i).Interface()) {
+				return true
+			}
+		}
+		return false
+	case Contains:
+		if s, ok := field.(string); ok {
+			substring, ok := c.Value.(string)
+			return ok && strings.Contains(s, substring)
+		}
+		if elements, ok := field.([]any); ok {
+			for _, element := range elements {
+				if queryEqual(element, c.Value) {
+					return true
+				}
+			}
+		}
+		return false
+	case Exists:
+		exists, _ := c.Value.(bool)
+		return (field != nil) == exists
+	default:
+		return false
+	}
+}
+
+// queryField returns the value of a field of a user, or nil when it is unset.
+func queryField(user User, field string) any {
+	if path, ok := strings.CutPrefix(field, metadataFieldPrefix); ok {
+		value, err := patchGet(user.Metadata, strings.Split(path, "."))
+		if err != nil {
+			return nil
+		}
+		return value
+	}
+
+	optional := func(value *string) any {
+		if value == nil {
+			return nil
+		}
+		return *value
+	}
+
+	switch field {
+	case "id":
+		return user.ID
+	case "email":
+		return user.Email
+	case "name":
+		return optional(user.Name)
+	case "phone_number":
+		return optional(user.PhoneNumber)
+	case "created_at":
+		return user.CreatedAt
+	case "is_verified":
+		return user.IsVerified
+	case "is_blocked":
+		return user.IsBlocked
+	default:
+		return nil
+	}
+}
+
+func queryEqual(a, b any) bool {
+	if cmp, ok := queryCompare(a, b); ok {
+		return cmp == 0
+	}
+
+	normalizedA, errA := normalizeJson(a)
+	normalizedB, errB := normalizeJson(b)
+	return errA == nil && errB == nil && reflect.DeepEqual(normalizedA, normalizedB)
+}
+
+// queryCompare compares two numbers, strings or times. Strings are compared to times as RFC 3339 timestamps.
+func queryCompare(a, b any) (int, bool) {
+	timeA, okA := queryTime(a)
+	timeB, okB := queryTime(b)
+	if okA || okB {
+		if !okA {
+			timeA, okA = parseQueryTime(a)
+		}
+		if !okB {
+			timeB, okB = parseQueryTime(b)
+		}
+		if okA && okB {
+			return timeA.Compare(timeB), true
+		}
+		return 0, false
+	}
+
+	if numberA, ok := queryNumber(a); ok {
+		if numberB, ok := queryNumber(b); ok {
+			switch {
+			case numberA < numberB:
+				return -1, true
+			case numberA > numberB:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+		return 0, false
+	}
+
+	stringA, okA := a.(string)
+	stringB, okB := b.(string)
+	if okA && okB {
+		return strings.Compare(stringA, stringB), true
+	}
+	return 0, false
+}
+
+// compareOrder compares two fields for sorting. Unset and incomparable fields come first.
+func compareOrder(a, b any) int {
+	if cmp, ok := queryCompare(a, b); ok {
+		return cmp
+	}
+
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	boolA, okA := a.(bool)
+	boolB, okB := b.(bool)
+	if okA && okB && boolA != boolB {
+		if boolA {
+			return 1
+		}
+		return -1
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func queryTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false
+		}
+		return *v, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func parseQueryTime(value any) (time.Time, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	parsed, err := time.Parse(time.RFC3339, s)
+	return parsed, err == nil
+}
+
+func queryNumber(value any) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,85 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchFallback(t *testing.T) {
+	tests := []struct {
+		name            string
+		searchBody      string
+		wantErr         bool
+		wantUnsupported bool
+		wantIDs         []string
+	}{
+		{
+			name:       "a 404 that is not an unknown route is an error and keeps the search endpoint",
+			searchBody: `{"message":"Field not found","error":"Not Found","statusCode":404}`,
+			wantErr:    true,
+		},
+		{
+			name:            "an unknown route falls back to a client-side search with metadata",
+			searchBody:      `{"message":"Cannot POST /users/search","error":"Not Found","statusCode":404}`,
+			wantUnsupported: true,
+			wantIDs:         []string{"u1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/users/search":
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(tt.searchBody))
+				case "/users/findByMetadata":
+					var payload struct {
+						IncludeMetadata bool `json:"includeMetadata"`
+					}
+					_ = json.NewDecoder(r.Body).Decode(&payload)
+
+					users := []map[string]any{
+						{"id": "u1", "created_at": "2024-01-01T00:00:00Z", "metadata": map[string]any{"plan": "pro", "seats": 5.0}},
+						{"id": "u2", "created_at": "2024-01-01T00:00:00Z", "metadata": map[string]any{"plan": "pro", "seats": 1.0}},
+					}
+					if !payload.IncludeMetadata {
+						for _, user := range users {
+							delete(user, "metadata")
+						}
+					}
+					_ = json.NewEncoder(w).Encode(map[string]any{"data": users, "page": 1, "total": 2, "hasNext": false})
+				default:
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+			}))
+			defer server.Close()
+
+			k := NewKobbleUsers(Config{Http: utils.NewHttpClient(utils.HttpClientConfig{BaseURL: server.URL})})
+			defer k.Close()
+
+			query := Query().Where("metadata.plan", Eq, "pro").And("metadata.seats", Gte, 2)
+			it := k.Search(query, nil)
+			var ids []string
+			for it.Next(context.Background()) {
+				for _, user := range it.Page().Data {
+					ids = append(ids, user.ID)
+				}
+			}
+
+			if err := it.Err(); (err != nil) != tt.wantErr {
+				t.Errorf("Search() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(ids) != len(tt.wantIDs) || (len(ids) > 0 && ids[0] != tt.wantIDs[0]) {
+				t.Errorf("Search() = %v, want %v", ids, tt.wantIDs)
+			}
+			if got := !isEndpointAvailable(&k.endpoints.searchUnsupportedUntil); got != tt.wantUnsupported {
+				t.Errorf("search endpoint unsupported = %v, want %v", got, tt.wantUnsupported)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReservationTtl     = 15 * time.Minute
+	reservationReleaseTimeout = 30 * time.Second
+)
+
+// The delays between the retries of an automatic release. They are variables so that tests can shorten them.
+var (
+	reservationReleaseRetryDelay    = 1 * time.Second
+	reservationReleaseMaxRetryDelay = 5 * time.Minute
+)
+
+// ReserveQuotaOptions is the configuration of a quota reservation.
+//
+//   - Ttl is how long the reservation is held before it is released automatically. Defaults to 15 minutes.
+//   - OnExpired is called once an abandoned reservation was released automatically. It is not called when the release is given up.
+type ReserveQuotaOptions struct {
+	Ttl       time.Duration
+	OnExpired func(reservation *Reservation)
+}
+
+type reservationState int
+
+const (
+	reservationPending reservationState = iota
+	reservationSettled
+	reservationExpired
+)
+
+// Reservation is an amount of quota consumed up front, to be settled once the real amount is known.
+//
+// Every reservation must end with either Commit or Release. Reservations left pending are released automatically
+// when they expire, so that the user is not charged for work that never completed. Once expired, a reservation can no
+// longer be committed or released. When the automatic release fails because the Kobble API is unavailable, it is retried
+// with backoff. It is not retried when it was rejected (e.g. for a deleted user), nor when it may have been applied
+// without its response being received, so that the user is never refunded twice: the cached quota usages of the user
+// are dropped instead.
+// Note that the automatic release relies on a timer of the current process: if the process dies, the reserved amount stays consumed.
+type Reservation struct {
+	UserID    string
+	QuotaName string
+	Amount    int
+	ExpiresAt time.Time
+
+	users KobbleUsers
+	mu    sync.Mutex
+	state reservationState
+	timer *time.Timer
+}
+
+// ReserveQuota consumes an amount of quota up front for a long-running operation.
+//
+// The amount is consumed atomically through ConsumeQuota, so the reservation fails with a *QuotaExceededError
+// (matching ErrQuotaExceeded) when there is not enough remaining credit.
+// Once the operation completes, call Commit with the amount actually used, or Release if it failed.
+//
+//   - @param ctx - The context of the request.
+//   - @param userId - The unique identifier for the user whose quota is being reserved.
+//   - @param quotaName - The name of the quota to reserve.
+//   - @param amount - The amount to reserve. Must be positive.
+func (k KobbleUsers) ReserveQuota(ctx context.Context, userId string, quotaName string, amount int, opts *ReserveQuotaOptions) (*Reservation, error) {
+	ttl := defaultReservationTtl
+	var onExpired func(reservation *Reservation)
+	if opts != nil {
+		if opts.Ttl > 0 {
+			ttl = opts.Ttl
+		}
+		onExpired = opts.OnExpired
+	}
+
+	if _, err := k.ConsumeQuota(ctx, userId, quotaName, amount); err != nil {
+		return nil, err
+	}
+
+	reservation := &Reservation{
+		UserID:    userId,
+		QuotaName: quotaName,
+		Amount:    amount,
+		ExpiresAt: time.Now().Add(ttl),
+		users:     k,
+	}
+	// Hold the lock so that expire never sees the timer unset, however short the ttl.
+	reservation.mu.Lock()
+	reservation.timer = time.AfterFunc(ttl, func() {
+		reservation.expire(onExpired)
+	})
+	reservation.mu.Unlock()
+
+	return reservation, nil
+}
+
+// expire marks an abandoned reservation as expired and releases it.
+func (r *Reservation) expire(onExpired func(reservation *Reservation)) {
+	r.mu.Lock()
+	if r.state != reservationPending {
+		r.mu.Unlock()
+		return
+	}
+	r.state = reservationExpired
+	r.mu.Unlock()
+
+	r.release(onExpired, 0)
+}
+
+// release gives the amount of an expired reservation back, retrying with backoff while the Kobble API is unavailable.
+func (r *Reservation) release(onExpired func(reservation *Reservation), failures int) {
+	ctx, cancel := context.WithTimeout(context.Background(), reservationReleaseTimeout)
+	defer cancel()
+
+	_, err := r.users.incrementQuotaUsage(ctx, r.UserID, r.QuotaName, -r.Amount)
+	if err != nil {
+		switch classifyMutationError(err) {
+		case mutationRetryable:
+			delay := reservationReleaseMaxRetryDelay
+			if failures < 16 {
+				delay = min(reservationReleaseRetryDelay<<failures, reservationReleaseMaxRetryDelay)
+			}
+			time.AfterFunc(delay, func() {
+				r.release(onExpired, failures+1)
+			})
+		case mutationUncertain:
+			r.users.InvalidateUser(r.UserID)
+		}
+		return
+	}
+
+	if onExpired != nil {
+		onExpired(r)
+	}
+}
+
+// settle marks the reservation as settled, or returns why it cannot be.
+// When apply fails, the reservation is left pending so that the caller can retry.
+func (r *Reservation) settle(apply func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.state {
+	case reservationSettled:
+		return ErrReservationSettled
+	case reservationExpired:
+		return ErrReservationExpired
+	}
+
+	if err := apply(); err != nil {
+		return err
+	}
+
+	r.state = reservationSettled
+	r.timer.Stop()
+	return nil
+}
+
+// Commit settles the reservation with the amount actually used.
+//
+// When less than the reserved amount was used, the difference is given back to the user.
+// When more was used, the extra amount is charged, even if it exceeds the limit of the quota.
+//
+//   - @param ctx - The context of the request.
+//   - @param actual - The amount actually used. Must not be negative.
+func (r *Reservation) Commit(ctx context.Context, actual int) error {
+	if actual < 0 {
+		return fmt.Errorf("invalid amount %d: must not be negative", actual)
+	}
+
+	return r.settle(func() error {
+		if diff := actual - r.Amount; diff != 0 {
+			_, err := r.users.incrementQuotaUsage(ctx, r.UserID, r.QuotaName, diff)
+			return err
+		}
+		return ctx.Err()
+	})
+}
+
+// Release cancels the reservation and gives the whole reserved amount back to the user.
+//
+//   - @param ctx - The context of the request.
+func (r *Reservation) Release(ctx context.Context) error {
+	return r.settle(func() error {
+		_, err := r.users.incrementQuotaUsage(ctx, r.UserID, r.QuotaName, -r.Amount)
+		return err
+	})
+}
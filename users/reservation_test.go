@@ -0,0 +1,94 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReservationExpire(t *testing.T) {
+	reservationReleaseRetryDelay = 10 * time.Millisecond
+	t.Cleanup(func() {
+		reservationReleaseRetryDelay = 1 * time.Second
+	})
+
+	tests := []struct {
+		name            string
+		statuses        []int
+		wantReleases    int32
+		wantExpired     bool
+		wantInvalidated bool
+	}{
+		{name: "released", statuses: []int{http.StatusCreated}, wantReleases: 1, wantExpired: true},
+		{name: "unavailable is retried", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusCreated}, wantReleases: 3, wantExpired: true},
+		{name: "unknown user is given up", statuses: []int{http.StatusNotFound}, wantReleases: 1},
+		{name: "server error is given up and invalidates the user", statuses: []int{http.StatusInternalServerError}, wantReleases: 1, wantInvalidated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var releases, listings atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/quotas/consumeUsage":
+					w.WriteHeader(http.StatusCreated)
+					_, _ = w.Write([]byte(`{"name":"credits","usage":5,"remaining":5,"limit":10}`))
+				case "/quotas/incrementUsage":
+					n := releases.Add(1)
+					status := tt.statuses[min(int(n), len(tt.statuses))-1]
+					w.WriteHeader(status)
+					if status == http.StatusCreated {
+						_, _ = w.Write([]byte(`{"name":"credits","usage":0,"remaining":10,"limit":10}`))
+					}
+				case "/users/listQuotas":
+					listings.Add(1)
+					_, _ = w.Write([]byte(`{"quotas":[{"name":"credits","usage":5,"remaining":5,"limit":10}]}`))
+				default:
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+			}))
+			defer server.Close()
+
+			k := NewKobbleUsers(Config{Http: utils.NewHttpClient(utils.HttpClientConfig{BaseURL: server.URL})})
+			defer k.Close()
+
+			var expired atomic.Bool
+			reservation, err := k.ReserveQuota(context.Background(), "u1", "credits", 5, &ReserveQuotaOptions{
+				Ttl:       20 * time.Millisecond,
+				OnExpired: func(*Reservation) { expired.Store(true) },
+			})
+			if err != nil {
+				t.Fatalf("ReserveQuota() error = %v", err)
+			}
+			// Fill the quotas cache, to tell whether the expiry dropped it.
+			if _, err := k.ListQuotas("u1", nil); err != nil {
+				t.Fatalf("ListQuotas() error = %v", err)
+			}
+
+			time.Sleep(200 * time.Millisecond)
+			if got := releases.Load(); got != tt.wantReleases {
+				t.Errorf("calls to /quotas/incrementUsage = %d, want %d", got, tt.wantReleases)
+			}
+			if got := expired.Load(); got != tt.wantExpired {
+				t.Errorf("OnExpired called = %v, want %v", got, tt.wantExpired)
+			}
+			if err := reservation.Release(context.Background()); !errors.Is(err, ErrReservationExpired) {
+				t.Errorf("Release() error = %v, want ErrReservationExpired", err)
+			}
+
+			_, _ = k.ListQuotas("u1", nil)
+			wantListings := int32(1)
+			if tt.wantInvalidated || tt.wantExpired {
+				wantListings = 2
+			}
+			if got := listings.Load(); got != wantListings {
+				t.Errorf("calls to /users/listQuotas = %d, want %d", got, wantListings)
+			}
+		})
+	}
+}
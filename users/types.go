@@ -1,21 +1,52 @@
 package users
 
 import (
+	"github.com/kobble-io/go-admin/common"
+	"github.com/kobble-io/go-admin/permissions"
 	"github.com/kobble-io/go-admin/utils"
+	"log/slog"
 	"time"
 )
 
 type User struct {
-	ID         string         `json:"id"`
-	Email      string         `json:"email"`
-	Name       *string        `json:"name"`
-	CreatedAt  time.Time      `json:"created_at"`
-	IsVerified bool           `json:"is_verified"`
-	Metadata   map[string]any `json:"metadata"`
+	ID               string         `json:"id"`
+	Email            string         `json:"email"`
+	Name             *string        `json:"name"`
+	PhoneNumber      *string        `json:"phone_number"`
+	CreatedAt        time.Time      `json:"created_at"`
+	IsVerified       bool           `json:"is_verified"`
+	IsBlocked        bool           `json:"is_blocked"`
+	BlockedReason    *string        `json:"blocked_reason"`
+	Metadata         map[string]any `json:"metadata"`
+	MetadataRevision *int64         `json:"metadata_revision,omitempty"`
 }
 
+// VersionedMetadata is the metadata of a user along with its revision.
+//
+//   - Revision is incremented by the Kobble API on every metadata change. Pass it to UpdateMetadataIf to only update
+//     metadata that did not change since it was read.
+//
+// User.MetadataRevision is nil when the Kobble API does not version metadata, in which case conditional updates are not supported.
+type VersionedMetadata struct {
+	Metadata map[string]any `json:"metadata"`
+	Revision int64          `json:"revision"`
+}
+
+// UserActiveProduct is a product a user is assigned to.
+//
+//   - ExpiresAt is the time at which the assignment ends, or nil if it does not expire.
 type UserActiveProduct struct {
 	Product
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// AssignProductOptions is the configuration of a product assignment.
+//
+//   - PriceID is the price the user is assigned at. Defaults to the default price of the product.
+//   - ExpiresAt is the time at which the assignment ends. Defaults to no expiration.
+type AssignProductOptions struct {
+	PriceID   string
+	ExpiresAt *time.Time
 }
 
 type QuotaUsage struct {
@@ -26,23 +57,40 @@ type QuotaUsage struct {
 	Limit     *int      `json:"limit"`
 }
 
-type Product struct {
-	ID    string  `json:"id"`
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
-}
+type Product = common.Product
 
+// Config is the configuration of the KobbleUsers client.
+//
+//   - Http is the HTTP client used to make requests to the Kobble API.
+//   - PermissionsCacheTtl is how long the permissions of a user are cached. Defaults to 1 minute.
+//   - QuotasCacheTtl is how long the quota usages of a user are cached. Defaults to 1 minute.
+//   - StaleCacheTtl is how long expired permissions and quota usages keep being served while they are refreshed in the background.
+//     Defaults to zero, which disables it. When enabled, a revoked permission can keep being granted for up to
+//     PermissionsCacheTtl + StaleCacheTtl after the revocation, unless InvalidateUser is called.
+//   - PermissionsCache is the cache holding the permissions of users. Defaults to an in-memory cache.
+//   - QuotasCache is the cache holding the quota usages of users. Defaults to an in-memory cache.
+//   - AuditLogger receives an audit record for every impersonation token requested. Defaults to no audit logging.
 type Config struct {
-	Http *utils.HttpClient
+	Http                *utils.HttpClient
+	PermissionsCacheTtl time.Duration
+	QuotasCacheTtl      time.Duration
+	StaleCacheTtl       time.Duration
+	PermissionsCache    utils.Cache[[]permissions.Permission]
+	QuotasCache         utils.Cache[[]QuotaUsage]
+	AuditLogger         *slog.Logger
 }
 
 type ApiUser struct {
-	ID         string         `json:"id"`
-	Email      string         `json:"email"`
-	Name       *string        `json:"name"`
-	CreatedAt  time.Time      `json:"created_at"`
-	IsVerified bool           `json:"is_verified"`
-	Metadata   map[string]any `json:"metadata"`
+	ID               string         `json:"id"`
+	Email            string         `json:"email"`
+	Name             *string        `json:"name"`
+	PhoneNumber      *string        `json:"phone_number"`
+	CreatedAt        time.Time      `json:"created_at"`
+	IsVerified       bool           `json:"is_verified"`
+	IsBlocked        bool           `json:"is_blocked"`
+	BlockedReason    *string        `json:"blocked_reason"`
+	Metadata         map[string]any `json:"metadata"`
+	MetadataRevision *int64         `json:"metadata_revision,omitempty"`
 }
 
 type ApiQuota struct {
@@ -75,12 +123,193 @@ type CreateUserPayload struct {
 	MarkPhoneNumberAsVerified bool           `json:"mark_phone_number_as_verified,omitempty"`
 }
 
+// The fields of a user that can be changed with KobbleUsers.Update.
+const (
+	UserFieldEmail       = "email"
+	UserFieldName        = "name"
+	UserFieldPhoneNumber = "phone_number"
+)
+
+// UpdateUserPayload holds the new values of the fields of a user.
+//
+//   - UpdateMask lists the fields to update (UserFieldEmail, UserFieldName, UserFieldPhoneNumber).
+//     Fields not listed are left untouched, which allows clearing the name by listing it with a nil Name.
+//     When empty, every non-zero field of the payload is updated.
+//
+// Note that the phone number should be in E.164 format (e.g. +14155552671). Other formats will be rejected.
+type UpdateUserPayload struct {
+	Email       string
+	Name        *string
+	PhoneNumber string
+	UpdateMask  []string
+}
+
 type UrlLink struct {
+	ID        string    `json:"id"`
 	Url       string    `json:"url"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
 
+// ImpersonationToken is a short-lived access token letting an actor act as a user.
+//
+//   - ID is the unique identifier of the impersonation session, as recorded in the audit logs.
+//   - Token is the access token, accepted by KobbleAuth.VerifyAccessToken and KobbleGateway.ParseToken.
+//   - ExpiresAt is the time at which the token expires.
+type ImpersonationToken struct {
+	ID        string    `json:"id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateLoginLinkOptions is the configuration of a login link.
+//
+//   - RedirectUrl is where the user is redirected after logging in. Defaults to the redirect URL of the application.
+//   - Ttl is how long the link remains valid. It is rounded up to the second. Defaults to the expiry set by Kobble.
+//   - SingleUse is whether the link can only be used once. Defaults to the behavior set by Kobble.
+//   - ApplicationID is the application the user logs in to. Defaults to the default application of the project.
+type CreateLoginLinkOptions struct {
+	RedirectUrl   string
+	Ttl           time.Duration
+	SingleUse     *bool
+	ApplicationID string
+}
+
 type IsAllowedPayload struct {
 	PermissionNames []string `json:"permissionNames"`
 	QuotaNames      []string `json:"quotaNames"`
 }
+
+// CheckMode defines how several permission or quota names are evaluated.
+//
+//   - ModeAll requires every name to be granted. This is the default.
+//   - ModeAny requires at least one name to be granted.
+type CheckMode string
+
+const (
+	ModeAll CheckMode = "all"
+	ModeAny CheckMode = "any"
+)
+
+// isSatisfied reports whether a check over total names, of which denied were not granted, passes.
+func (m CheckMode) isSatisfied(total int, denied int) bool {
+	if total == 0 {
+		return false
+	}
+
+	if m == ModeAny {
+		return denied < total
+	}
+	return denied == 0
+}
+
+type PermissionCheckResult struct {
+	Granted            bool     `json:"granted"`
+	MissingPermissions []string `json:"missing_permissions"`
+}
+
+type QuotaCheckResult struct {
+	Granted         bool     `json:"granted"`
+	ExhaustedQuotas []string `json:"exhausted_quotas"`
+}
+
+type IsAllowedResult struct {
+	Allowed            bool     `json:"allowed"`
+	MissingPermissions []string `json:"missing_permissions"`
+	ExhaustedQuotas    []string `json:"exhausted_quotas"`
+}
+
+// AuthorizeRequest describes what a user needs in order to be authorized.
+//
+//   - PermissionNames are the permissions the user must hold.
+//   - QuotaNames are the quotas the user must have remaining credit on.
+//   - Mode is whether all (ModeAll) or any (ModeAny) of the names are required. Default is ModeAll.
+//   - NoCache bypasses the cache when the decision is computed client-side.
+type AuthorizeRequest struct {
+	PermissionNames []string  `json:"permissionNames"`
+	QuotaNames      []string  `json:"quotaNames"`
+	Mode            CheckMode `json:"mode,omitempty"`
+	NoCache         bool      `json:"-"`
+}
+
+// DecisionReason explains an authorization Decision.
+type DecisionReason string
+
+const (
+	DecisionReasonGranted            DecisionReason = "granted"
+	DecisionReasonEmptyRequest       DecisionReason = "empty_request"
+	DecisionReasonMissingPermissions DecisionReason = "missing_permissions"
+	DecisionReasonExhaustedQuotas    DecisionReason = "exhausted_quotas"
+)
+
+// Decision is the outcome of an authorization check.
+//
+//   - Allowed is true when the user is authorized.
+//   - MissingPermissions lists the requested permissions the user does not hold.
+//   - ExhaustedQuotas lists the requested quotas the user has no remaining credit on.
+//   - Reason explains why the user is (or is not) authorized.
+type Decision struct {
+	Allowed            bool           `json:"allowed"`
+	MissingPermissions []string       `json:"missing_permissions"`
+	ExhaustedQuotas    []string       `json:"exhausted_quotas"`
+	Reason             DecisionReason `json:"reason"`
+}
+
+// BatchIsAllowedOptions is the configuration of a batch authorization check.
+//
+//   - NoCache bypasses the cache and fetches fresh data for every user.
+//   - Mode is whether all (ModeAll) or any (ModeAny) of the names are required. Default is ModeAll.
+//   - Concurrency is the maximum number of users checked at the same time. Defaults to 10.
+type BatchIsAllowedOptions struct {
+	NoCache     bool
+	Mode        CheckMode
+	Concurrency int
+}
+
+// BatchIsAllowedResult is the outcome of the authorization check of a single user in a batch.
+//
+//   - UserID is the unique identifier of the user.
+//   - Allowed is true when the user is authorized.
+//   - Decision details the authorization decision. It is empty when Err is set.
+//   - Err is the error encountered while checking this user, if any.
+type BatchIsAllowedResult struct {
+	UserID   string
+	Allowed  bool
+	Decision Decision
+	Err      error
+}
+
+// UsageReporterConfig is the configuration of a UsageReporter.
+//
+//   - FlushInterval is the interval at which pending usage is reported to Kobble. Defaults to 5 seconds.
+//   - MaxPendingKeys is the number of distinct (user, quota) pairs that triggers an early flush. Defaults to 1000.
+//   - MaxRetries is the number of times reporting the usage of a (user, quota) pair is retried within a flush. Defaults to 3.
+//     Only reports that certainly did not reach Kobble are retried, so that usage is never applied twice,
+//     and only while the Kobble API is unavailable or rate limiting (408, 429 and 503 responses, or connection failures).
+//   - RetryBackoff is the delay before the first retry, doubled on each subsequent retry. Defaults to 500 milliseconds.
+type UsageReporterConfig struct {
+	FlushInterval  time.Duration
+	MaxPendingKeys int
+	MaxRetries     int
+	RetryBackoff   time.Duration
+}
+
+// UsageReporterStats is a snapshot of the metrics of a UsageReporter.
+//
+//   - PendingKeys is the number of (user, quota) pairs with usage not reported yet.
+//   - PendingDelta is the sum of the usage not reported yet.
+//   - ReportedDelta is the sum of the usage successfully reported since the reporter was created.
+//   - Flushes is the number of flushes performed.
+//   - FailedReports is the number of (user, quota) pairs whose report failed after all retries. Their usage is kept for the next flush.
+//   - RejectedReports is the number of (user, quota) pairs whose report was rejected by the Kobble API, e.g. for an unknown user or quota.
+//     Their usage is dropped, as reporting it again would fail the same way.
+//   - UncertainReports is the number of (user, quota) pairs whose report may or may not have been applied, e.g. after a timeout.
+//     Their usage is dropped rather than reported again, so that users are never charged twice.
+type UsageReporterStats struct {
+	PendingKeys      int
+	PendingDelta     int64
+	ReportedDelta    int64
+	Flushes          int64
+	FailedReports    int64
+	RejectedReports  int64
+	UncertainReports int64
+}
@@ -0,0 +1,343 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kobble-io/go-admin/utils"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultUsageFlushInterval  = 5 * time.Second
+	defaultUsageMaxPendingKeys = 1000
+	defaultUsageMaxRetries     = 3
+	defaultUsageRetryBackoff   = 500 * time.Millisecond
+)
+
+type usageKey struct {
+	userId    string
+	quotaName string
+}
+
+// pendingUsage holds the usage recorded by the UsageReporters of a KobbleUsers client and not reported yet,
+// so that quota checks account for it.
+type pendingUsage struct {
+	mu     sync.Mutex
+	deltas map[usageKey]int
+}
+
+func newPendingUsage() *pendingUsage {
+	return &pendingUsage{
+		deltas: make(map[usageKey]int),
+	}
+}
+
+func (p *pendingUsage) add(key usageKey, delta int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.deltas[key] += delta
+	if p.deltas[key] == 0 {
+		delete(p.deltas, key)
+	}
+}
+
+// apply returns a copy of quotas adjusted with the pending usage of the user.
+func (p *pendingUsage) apply(userId string, quotas []QuotaUsage) []QuotaUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.deltas) == 0 {
+		return quotas
+	}
+
+	adjusted := make([]QuotaUsage, len(quotas))
+	for i, quota := range quotas {
+		adjusted[i] = quota
+		delta, ok := p.deltas[usageKey{userId: userId, quotaName: quota.Name}]
+		if !ok {
+			continue
+		}
+
+		adjusted[i].Usage += delta
+		if quota.Remaining != nil {
+			remaining := *quota.Remaining - delta
+			adjusted[i].Remaining = &remaining
+		}
+	}
+
+	return adjusted
+}
+
+// UsageReporter aggregates quota usage increments in memory and reports them to Kobble in batches.
+//
+// It is meant for high-volume metering where calling IncrementQuotaUsage on every request is too costly.
+// The usage recorded but not reported yet is taken into account by the quota checks of the KobbleUsers client
+// it was created from (ListQuotas, HasRemainingQuota, IsAllowed, ...).
+//
+// Call Close on shutdown to report the remaining usage.
+type UsageReporter struct {
+	users   *KobbleUsers
+	config  UsageReporterConfig
+	mu      sync.Mutex
+	buffer  map[usageKey]int
+	flushMu sync.Mutex
+	trigger chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	reportedDelta    atomic.Int64
+	flushes          atomic.Int64
+	failedReports    atomic.Int64
+	rejectedReports  atomic.Int64
+	uncertainReports atomic.Int64
+}
+
+// NewUsageReporter creates a new UsageReporter reporting usage through the given client, and starts its background flusher.
+func NewUsageReporter(users *KobbleUsers, config UsageReporterConfig) *UsageReporter {
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultUsageFlushInterval
+	}
+	if config.MaxPendingKeys <= 0 {
+		config.MaxPendingKeys = defaultUsageMaxPendingKeys
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = defaultUsageMaxRetries
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultUsageRetryBackoff
+	}
+
+	r := &UsageReporter{
+		users:   users,
+		config:  config,
+		buffer:  make(map[usageKey]int),
+		trigger: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go r.run()
+
+	return r
+}
+
+func (r *UsageReporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = r.Flush(context.Background())
+		case <-r.trigger:
+			_ = r.Flush(context.Background())
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Add records that a user used an amount of a quota. The usage is reported on the next flush.
+//
+// A negative amount decrements the usage.
+//
+//   - @param userId - The unique identifier for the user whose quota is being used.
+//   - @param quotaName - The name of the quota being used.
+//   - @param amount - The amount used.
+func (r *UsageReporter) Add(userId string, quotaName string, amount int) {
+	if amount == 0 {
+		return
+	}
+
+	key := usageKey{userId: userId, quotaName: quotaName}
+	r.users.pendingUsage.add(key, amount)
+
+	r.mu.Lock()
+	r.buffer[key] += amount
+	full := len(r.buffer) >= r.config.MaxPendingKeys
+	r.mu.Unlock()
+
+	if full {
+		select {
+		case r.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush reports all the pending usage to Kobble.
+//
+// Each (user, quota) pair is retried up to MaxRetries times while the Kobble API is unavailable or rate limiting.
+// The usage of pairs that still fail is kept and reported on the next flush; the returned error joins their errors.
+// When a report is rejected by the Kobble API (e.g. for an unknown user or quota), its usage is dropped.
+// When a report may have been applied without its response being received (e.g. on a timeout), its usage is
+// dropped instead of being reported again, and the cached quota usages of the user are invalidated.
+// ctx bounds the whole flush, including the requests in progress.
+func (r *UsageReporter) Flush(ctx context.Context) error {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+
+	r.mu.Lock()
+	batch := r.buffer
+	r.buffer = make(map[usageKey]int)
+	r.mu.Unlock()
+
+	r.flushes.Add(1)
+
+	var errs []error
+	for key, delta := range batch {
+		if delta == 0 {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			// Nothing was sent for this pair, so its usage is kept for the next flush.
+			r.mu.Lock()
+			r.buffer[key] += delta
+			r.mu.Unlock()
+			continue
+		}
+
+		if err := r.report(ctx, key, delta); err != nil {
+			switch classifyMutationError(err) {
+			case mutationRetryable:
+				r.failedReports.Add(1)
+				errs = append(errs, fmt.Errorf("failed to report usage of quota %s for user %s: %w", key.quotaName, key.userId, err))
+
+				r.mu.Lock()
+				r.buffer[key] += delta
+				r.mu.Unlock()
+
+			case mutationRejected:
+				r.rejectedReports.Add(1)
+				errs = append(errs, fmt.Errorf("usage of quota %s for user %s was rejected: %w", key.quotaName, key.userId, err))
+
+				r.users.pendingUsage.add(key, -delta)
+
+			default:
+				r.uncertainReports.Add(1)
+				errs = append(errs, fmt.Errorf("usage of quota %s for user %s may not have been reported: %w", key.quotaName, key.userId, err))
+
+				r.users.pendingUsage.add(key, -delta)
+				r.users.InvalidateUser(key.userId)
+			}
+			continue
+		}
+
+		r.users.pendingUsage.add(key, -delta)
+		r.reportedDelta.Add(int64(delta))
+	}
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (r *UsageReporter) report(ctx context.Context, key usageKey, delta int) error {
+	backoff := r.config.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				// The previous attempt was not applied, so the usage is kept for the next flush.
+				return err
+			}
+		}
+
+		_, err = r.users.incrementQuotaUsage(ctx, key.userId, key.quotaName, delta)
+		if err == nil || classifyMutationError(err) != mutationRetryable {
+			return err
+		}
+	}
+
+	return err
+}
+
+// mutationFailure is the outcome of a failed request changing the usage of a quota.
+type mutationFailure int
+
+const (
+	// mutationUncertain means the request may have been applied without its response being received.
+	mutationUncertain mutationFailure = iota
+	// mutationRetryable means the request was not applied, and may succeed if sent again later.
+	mutationRetryable
+	// mutationRejected means the request was not applied, and never will be (e.g. for an unknown user or quota).
+	mutationRejected
+)
+
+// classifyMutationError tells whether a failed quota usage change can be safely sent again.
+//
+// Only requests that certainly did not change anything on Kobble are retryable: those the Kobble API
+// answered with a timeout, rate limiting or unavailability status, and those whose connection could not be established.
+func classifyMutationError(err error) mutationFailure {
+	var httpErr *utils.HttpError
+	if errors.As(err, &httpErr) {
+		switch {
+		case httpErr.StatusCode == http.StatusRequestTimeout,
+			httpErr.StatusCode == http.StatusTooManyRequests,
+			httpErr.StatusCode == http.StatusServiceUnavailable:
+			return mutationRetryable
+		case httpErr.StatusCode < http.StatusInternalServerError:
+			return mutationRejected
+		}
+		return mutationUncertain
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return mutationRetryable
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return mutationRetryable
+	}
+	return mutationUncertain
+}
+
+// Stats returns a snapshot of the metrics of the reporter.
+func (r *UsageReporter) Stats() UsageReporterStats {
+	r.mu.Lock()
+	pendingKeys := len(r.buffer)
+	var pendingDelta int64
+	for _, delta := range r.buffer {
+		pendingDelta += int64(delta)
+	}
+	r.mu.Unlock()
+
+	return UsageReporterStats{
+		PendingKeys:      pendingKeys,
+		PendingDelta:     pendingDelta,
+		ReportedDelta:    r.reportedDelta.Load(),
+		Flushes:          r.flushes.Load(),
+		FailedReports:    r.failedReports.Load(),
+		RejectedReports:  r.rejectedReports.Load(),
+		UncertainReports: r.uncertainReports.Load(),
+	}
+}
+
+// Close stops the background flusher and reports the remaining usage.
+//
+// It should be called on graceful shutdown. Usage that could not be reported is returned as an error.
+func (r *UsageReporter) Close(ctx context.Context) error {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+
+	return r.Flush(ctx)
+}
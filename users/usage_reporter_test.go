@@ -0,0 +1,121 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"github.com/kobble-io/go-admin/utils"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClassifyMutationError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want mutationFailure
+	}{
+		{name: "request timeout", err: &utils.HttpError{StatusCode: http.StatusRequestTimeout}, want: mutationRetryable},
+		{name: "rate limited", err: &utils.HttpError{StatusCode: http.StatusTooManyRequests}, want: mutationRetryable},
+		{name: "unavailable", err: &utils.HttpError{StatusCode: http.StatusServiceUnavailable}, want: mutationRetryable},
+		{name: "dial failure", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: mutationRetryable},
+		{name: "dns failure", err: &net.DNSError{Err: "no such host"}, want: mutationRetryable},
+		{name: "bad request", err: &utils.HttpError{StatusCode: http.StatusBadRequest}, want: mutationRejected},
+		{name: "forbidden", err: &utils.HttpError{StatusCode: http.StatusForbidden}, want: mutationRejected},
+		{name: "unknown user", err: &utils.HttpError{StatusCode: http.StatusNotFound}, want: mutationRejected},
+		{name: "internal error", err: &utils.HttpError{StatusCode: http.StatusInternalServerError}, want: mutationUncertain},
+		{name: "gateway timeout", err: &utils.HttpError{StatusCode: http.StatusGatewayTimeout}, want: mutationUncertain},
+		{name: "read failure", err: &net.OpError{Op: "read", Err: errors.New("connection reset")}, want: mutationUncertain},
+		{name: "canceled", err: context.Canceled, want: mutationUncertain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyMutationError(tt.err); got != tt.want {
+				t.Errorf("classifyMutationError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageReporterFlush(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantCalls     int32
+		wantPending   int
+		wantFailed    int64
+		wantRejected  int64
+		wantUncertain int64
+	}{
+		{name: "reported", status: http.StatusCreated, wantCalls: 1},
+		{name: "rate limited is retried and kept", status: http.StatusTooManyRequests, wantCalls: 3, wantPending: 1, wantFailed: 1},
+		{name: "unknown user is dropped without retries", status: http.StatusNotFound, wantCalls: 1, wantRejected: 1},
+		{name: "server error is dropped without retries", status: http.StatusInternalServerError, wantCalls: 1, wantUncertain: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/quotas/incrementUsage" {
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusCreated {
+					_, _ = w.Write([]byte(`{"name":"credits","usage":1,"remaining":9,"limit":10}`))
+				}
+			}))
+			defer server.Close()
+
+			k := NewKobbleUsers(Config{Http: utils.NewHttpClient(utils.HttpClientConfig{BaseURL: server.URL})})
+			defer k.Close()
+			reporter := NewUsageReporter(k, UsageReporterConfig{FlushInterval: time.Hour, MaxRetries: 2, RetryBackoff: time.Millisecond})
+
+			reporter.Add("u1", "credits", 1)
+			err := reporter.Close(context.Background())
+			if (err != nil) != (tt.status != http.StatusCreated) {
+				t.Errorf("Close() error = %v", err)
+			}
+
+			stats := reporter.Stats()
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+			if stats.PendingKeys != tt.wantPending || stats.FailedReports != tt.wantFailed ||
+				stats.RejectedReports != tt.wantRejected || stats.UncertainReports != tt.wantUncertain {
+				t.Errorf("Stats() = %+v", stats)
+			}
+		})
+	}
+}
+
+func TestUsageReporterFlushCanceled(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	k := NewKobbleUsers(Config{Http: utils.NewHttpClient(utils.HttpClientConfig{BaseURL: server.URL})})
+	defer k.Close()
+	reporter := NewUsageReporter(k, UsageReporterConfig{FlushInterval: time.Hour})
+	reporter.Add("u1", "credits", 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := reporter.Close(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Close() error = %v, want context.Canceled", err)
+	}
+	if got := calls.Load(); got != 0 {
+		t.Errorf("calls = %d, want 0", got)
+	}
+	if stats := reporter.Stats(); stats.PendingKeys != 1 || stats.UncertainReports != 0 {
+		t.Errorf("Stats() = %+v, want the usage to be kept", stats)
+	}
+}
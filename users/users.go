@@ -1,11 +1,15 @@
 package users
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/kobble-io/go-admin/common"
 	"github.com/kobble-io/go-admin/permissions"
 	"github.com/kobble-io/go-admin/utils"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,42 +19,148 @@ import (
 // You can also use it to manage user metadata, permissions, and quotas.
 type KobbleUsers struct {
 	config           Config
-	permissionsCache *utils.Cache[[]permissions.Permission]
-	quotasCache      *utils.Cache[[]QuotaUsage]
+	permissionsCache *utils.LoadingCache[[]permissions.Permission]
+	quotasCache      *utils.LoadingCache[[]QuotaUsage]
+	stoppers         []func()
+	endpoints        *serverEndpoints
+	pendingUsage     *pendingUsage
 }
 
+const (
+	defaultCacheTtl             = 1 * time.Minute
+	defaultCacheMaxEntries      = 10000
+	defaultCacheCleanupInterval = 1 * time.Minute
+)
+
 // NewKobbleUsers creates a new instance of the KobbleUsers client.
 //
 // @param config - The configuration for the Kobble instance.
 func NewKobbleUsers(config Config) *KobbleUsers {
-	defaultTtl := 1 * time.Minute / time.Second
-	return &KobbleUsers{
-		config: config,
-		permissionsCache: utils.NewCache[[]permissions.Permission](utils.CacheConfig{
-			DefaultTtl: &defaultTtl,
-		}),
-		quotasCache: utils.NewCache[[]QuotaUsage](utils.CacheConfig{
-			DefaultTtl: &defaultTtl,
-		}),
+	if config.PermissionsCacheTtl <= 0 {
+		config.PermissionsCacheTtl = defaultCacheTtl
+	}
+	if config.QuotasCacheTtl <= 0 {
+		config.QuotasCacheTtl = defaultCacheTtl
+	}
+	if config.StaleCacheTtl < 0 {
+		config.StaleCacheTtl = 0
+	}
+
+	k := &KobbleUsers{
+		config:       config,
+		endpoints:    &serverEndpoints{},
+		pendingUsage: newPendingUsage(),
+	}
+
+	permissionsCache := config.PermissionsCache
+	if permissionsCache == nil {
+		cache := utils.NewMemoryCache[[]permissions.Permission](utils.CacheConfig{
+			MaxEntries:      defaultCacheMaxEntries,
+			CleanupInterval: defaultCacheCleanupInterval,
+		})
+		permissionsCache = cache
+		k.stoppers = append(k.stoppers, cache.Stop)
 	}
+	k.permissionsCache = utils.NewLoadingCache(permissionsCache, utils.LoadingCacheConfig{
+		Ttl:      config.PermissionsCacheTtl,
+		StaleTtl: config.StaleCacheTtl,
+	})
+
+	quotasCache := config.QuotasCache
+	if quotasCache == nil {
+		cache := utils.NewMemoryCache[[]QuotaUsage](utils.CacheConfig{
+			MaxEntries:      defaultCacheMaxEntries,
+			CleanupInterval: defaultCacheCleanupInterval,
+		})
+		quotasCache = cache
+		k.stoppers = append(k.stoppers, cache.Stop)
+	}
+	k.quotasCache = utils.NewLoadingCache(quotasCache, utils.LoadingCacheConfig{
+		Ttl:      config.QuotasCacheTtl,
+		StaleTtl: config.StaleCacheTtl,
+	})
+
+	return k
+}
+
+// Close stops the background workers that sweep the default in-memory permissions and quotas caches.
+//
+// Caches provided through the Config are left untouched.
+func (k KobbleUsers) Close() {
+	for _, stop := range k.stoppers {
+		stop()
+	}
+}
+
+// serverEndpoints remembers which optional endpoints the Kobble API does not expose,
+// so that fallbacks are used right away instead of failing a request first.
+type serverEndpoints struct {
+	decisionUnsupportedUntil atomic.Int64
+	consumeUnsupportedUntil  atomic.Int64
+	searchUnsupportedUntil   atomic.Int64
+}
+
+const unsupportedEndpointRetryDelay = 10 * time.Minute
+
+func isEndpointAvailable(unsupportedUntil *atomic.Int64) bool {
+	return time.Now().UnixMilli() >= unsupportedUntil.Load()
+}
+
+func markEndpointUnsupported(unsupportedUntil *atomic.Int64) {
+	unsupportedUntil.Store(time.Now().Add(unsupportedEndpointRetryDelay).UnixMilli())
 }
 
 func (k KobbleUsers) userCacheKey(userId string) string {
 	return "user:" + userId
 }
 
+// InvalidateUser drops the cached permissions and quota usages of a user.
+//
+// The next permission or quota check for this user will fetch fresh data from the Kobble API.
+//
+//   - @param userId - The unique identifier for the user whose cached data is being dropped.
+func (k KobbleUsers) InvalidateUser(userId string) {
+	ctx := context.Background()
+	_ = k.permissionsCache.Delete(ctx, k.userCacheKey(userId))
+	_ = k.quotasCache.Delete(ctx, k.userCacheKey(userId))
+}
+
+// InvalidateAllQuotas drops the cached quota usages of every user, for instance after resetting the usage of a quota for all users.
+//
+// The next quota check for any user will fetch fresh data from the Kobble API.
+// Note that the quota usages cached in a Config.QuotasCache that cannot be cleared, such as a KeyValueCache shared
+// with other processes, are not dropped: they are only refreshed once they expire, after QuotasCacheTtl.
+// Use InvalidateUser to drop the cached quota usages of a given user from such a cache.
+func (k KobbleUsers) InvalidateAllQuotas() {
+	k.quotasCache.Clear()
+}
+
 func (k KobbleUsers) transformApiUser(apiUser ApiUser) *User {
 	metadata := make(map[string]any)
 	if apiUser.Metadata != nil {
 		metadata = apiUser.Metadata
 	}
 	return &User{
-		ID:         apiUser.ID,
-		Email:      apiUser.Email,
-		Name:       apiUser.Name,
-		CreatedAt:  apiUser.CreatedAt,
-		IsVerified: apiUser.IsVerified,
-		Metadata:   metadata,
+		ID:               apiUser.ID,
+		Email:            apiUser.Email,
+		Name:             apiUser.Name,
+		PhoneNumber:      apiUser.PhoneNumber,
+		CreatedAt:        apiUser.CreatedAt,
+		IsVerified:       apiUser.IsVerified,
+		IsBlocked:        apiUser.IsBlocked,
+		BlockedReason:    apiUser.BlockedReason,
+		Metadata:         metadata,
+		MetadataRevision: apiUser.MetadataRevision,
+	}
+}
+
+func (k KobbleUsers) transformApiQuota(apiQuota ApiQuota) QuotaUsage {
+	return QuotaUsage{
+		Name:      apiQuota.Name,
+		Usage:     apiQuota.Usage,
+		ExpiresAt: apiQuota.ExpiresAt,
+		Remaining: &apiQuota.Remaining,
+		Limit:     &apiQuota.Limit,
 	}
 }
 
@@ -58,13 +168,53 @@ func (k KobbleUsers) transformApiUser(apiUser ApiUser) *User {
 //
 //   - @param userId - The unique identifier for the user to create a login link for.
 func (k KobbleUsers) CreateLoginLink(userId string) (UrlLink, error) {
-	var result UrlLink
-	err := k.config.Http.PostJson("/users/mintLoginLink", map[string]string{
+	return k.CreateLoginLinkWithOptions(userId, nil)
+}
+
+// CreateLoginLinkWithOptions creates a login link for a user, with a custom redirect URL, expiry or usage.
+//
+// The ID of the returned link can be passed to RevokeLoginLink to invalidate it before it expires.
+//
+//   - @param userId - The unique identifier for the user to create a login link for.
+//   - @param options - The configuration of the link. Nil uses the defaults of Kobble.
+func (k KobbleUsers) CreateLoginLinkWithOptions(userId string, options *CreateLoginLinkOptions) (UrlLink, error) {
+	payload := map[string]any{
 		"userId": userId,
-	}, &result, http.StatusCreated)
+	}
+
+	if options != nil {
+		if options.Ttl < 0 {
+			return UrlLink{}, fmt.Errorf("invalid login link ttl: %s", options.Ttl)
+		}
+
+		if options.RedirectUrl != "" {
+			payload["redirectUrl"] = options.RedirectUrl
+		}
+		if options.Ttl > 0 {
+			payload["ttlSeconds"] = int64((options.Ttl + time.Second - 1) / time.Second)
+		}
+		if options.SingleUse != nil {
+			payload["singleUse"] = *options.SingleUse
+		}
+		if options.ApplicationID != "" {
+			payload["applicationId"] = options.ApplicationID
+		}
+	}
+
+	var result UrlLink
+	err := k.config.Http.PostJson("/users/mintLoginLink", payload, &result, http.StatusCreated)
 	return result, err
 }
 
+// RevokeLoginLink invalidates a login link before it expires.
+//
+//   - @param linkId - The ID of the link, as returned by CreateLoginLinkWithOptions.
+func (k KobbleUsers) RevokeLoginLink(linkId string) error {
+	return k.config.Http.PostJson("/users/revokeLoginLink", map[string]string{
+		"linkId": linkId,
+	}, nil, http.StatusCreated)
+}
+
 // Create a new user on your Kobble instance manually.
 //
 // While both email and phoneNumber are optional, at least one of them must be provided.
@@ -79,6 +229,108 @@ func (k KobbleUsers) Create(payload CreateUserPayload) (*User, error) {
 	return k.transformApiUser(result), nil
 }
 
+// Update changes the email, name or phone number of a user.
+//
+// Only the fields listed in the UpdateMask of the payload are changed. When the mask is empty, every non-zero field is changed.
+// Note that the phone number should be in E.164 format (e.g. +14155552671). Other formats will be rejected.
+func (k KobbleUsers) Update(userId string, payload UpdateUserPayload) (*User, error) {
+	mask := payload.UpdateMask
+	if len(mask) == 0 {
+		if payload.Email != "" {
+			mask = append(mask, UserFieldEmail)
+		}
+		if payload.Name != nil {
+			mask = append(mask, UserFieldName)
+		}
+		if payload.PhoneNumber != "" {
+			mask = append(mask, UserFieldPhoneNumber)
+		}
+	}
+
+	fields := map[string]any{}
+	for _, field := range mask {
+		switch field {
+		case UserFieldEmail:
+			fields["email"] = payload.Email
+		case UserFieldName:
+			fields["name"] = payload.Name
+		case UserFieldPhoneNumber:
+			fields["phone_number"] = payload.PhoneNumber
+		default:
+			return nil, fmt.Errorf("invalid update mask field: %s", field)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, errors.New("nothing to update: the payload has no field set")
+	}
+
+	var result ApiUser
+	err := k.config.Http.PostJson("/users/update", map[string]any{
+		"userId": userId,
+		"fields": fields,
+	}, &result, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+	return k.transformApiUser(result), nil
+}
+
+// Delete permanently deletes a user from your Kobble instance.
+//
+//   - @param userId - The unique identifier for the user to delete.
+func (k KobbleUsers) Delete(userId string) error {
+	err := k.config.Http.PostJson("/users/delete", map[string]any{
+		"userId": userId,
+	}, nil, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+
+	k.InvalidateUser(userId)
+	return nil
+}
+
+// Block suspends a user.
+//
+// A blocked user cannot sign in anymore, and permission checks on this user fail with ErrUserBlocked.
+//
+//   - @param userId - The unique identifier for the user to block.
+//   - @param reason - Why the user is blocked. Optional.
+func (k KobbleUsers) Block(userId string, reason string) (*User, error) {
+	payload := map[string]any{
+		"userId": userId,
+	}
+	if reason != "" {
+		payload["reason"] = reason
+	}
+
+	var result ApiUser
+	err := k.config.Http.PostJson("/users/block", payload, &result, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	k.InvalidateUser(userId)
+	return k.transformApiUser(result), nil
+}
+
+// Unblock lifts the suspension of a blocked user.
+//
+//   - @param userId - The unique identifier for the user to unblock.
+func (k KobbleUsers) Unblock(userId string) (*User, error) {
+	var result ApiUser
+	err := k.config.Http.PostJson("/users/unblock", map[string]any{
+		"userId": userId,
+	}, &result, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	k.InvalidateUser(userId)
+	return k.transformApiUser(result), nil
+}
+
 type GetUserOptions struct {
 	IncludeMetadata bool `json:"include_metadata,omitempty"`
 }
@@ -91,8 +343,12 @@ func (k KobbleUsers) GetById(userId string, options *GetUserOptions) (*User, err
 	if options != nil {
 		includeMetadata = options.IncludeMetadata
 	}
+	return k.getById(context.Background(), userId, includeMetadata)
+}
+
+func (k KobbleUsers) getById(ctx context.Context, userId string, includeMetadata bool) (*User, error) {
 	var result ApiUser
-	err := k.config.Http.GetJson("/users/findById", map[string]string{
+	err := k.config.Http.GetJsonWithContext(ctx, "/users/findById", map[string]string{
 		"userId":          userId,
 		"includeMetadata": strconv.FormatBool(includeMetadata),
 	}, &result, http.StatusOK)
@@ -151,7 +407,7 @@ type ListUsersOptions struct {
 //
 // You can also include the user's metadata in the response by setting the `IncludeMetadata` option to `true`.
 func (k KobbleUsers) FindByMetadata(metadata map[string]any, options *ListUsersOptions) (common.Pagination[User], error) {
-	page, limit := 1, 50
+	page, limit, includeMetadata := 1, 50, false
 	if options != nil {
 		if options.Page > page {
 			page = options.Page
@@ -160,13 +416,16 @@ func (k KobbleUsers) FindByMetadata(metadata map[string]any, options *ListUsersO
 		if options.Limit != limit && options.Limit > 0 {
 			limit = options.Limit
 		}
+
+		includeMetadata = options.IncludeMetadata
 	}
 
 	var result common.Pagination[User]
 	err := k.config.Http.PostJson("/users/findByMetadata", map[string]any{
-		"metadata": metadata,
-		"page":     page,
-		"limit":    limit,
+		"metadata":        metadata,
+		"page":            page,
+		"limit":           limit,
+		"includeMetadata": includeMetadata,
 	}, &result, http.StatusOK)
 	if err != nil {
 		return common.Pagination[User]{}, err
@@ -175,20 +434,39 @@ func (k KobbleUsers) FindByMetadata(metadata map[string]any, options *ListUsersO
 	return result, nil
 }
 
-// PatchMetadata updates a user's metadata.
+// PatchMetadata merges metadata into the top level of a user's metadata and returns the resulting metadata.
+//
+// Use MergePatchMetadata to merge nested objects, or JsonPatchMetadata for finer operations.
 func (k KobbleUsers) PatchMetadata(userId string, metadata map[string]any) (map[string]any, error) {
-	err := k.config.Http.PostJson("/users/patchMetadata", map[string]any{
+	return k.patchMetadata(context.Background(), userId, metadata)
+}
+
+func (k KobbleUsers) patchMetadata(ctx context.Context, userId string, metadata map[string]any) (map[string]any, error) {
+	var result VersionedMetadata
+	err := k.config.Http.PostJsonWithContext(ctx, "/users/patchMetadata", map[string]any{
 		"userId":   userId,
 		"metadata": metadata,
-	}, nil, http.StatusCreated)
-	if err != nil {
+	}, &result, http.StatusCreated)
+	// Older versions of the Kobble API answer without a body.
+	if err != nil && !errors.Is(err, utils.ErrEmptyResponse) {
 		return nil, err
 	}
 
-	return metadata, nil
+	if result.Metadata != nil {
+		return result.Metadata, nil
+	}
+
+	// The Kobble API did not return the resulting metadata, fetch it.
+	user, err := k.getById(ctx, userId, true)
+	if err != nil {
+		return nil, err
+	}
+	return user.Metadata, nil
 }
 
 // UpdateMetadata replaces a user's metadata.
+//
+// The last update wins. Use UpdateMetadataIf or ModifyMetadata when the metadata can be updated concurrently.
 func (k KobbleUsers) UpdateMetadata(userId string, metadata map[string]any) (map[string]any, error) {
 	err := k.config.Http.PostJson("/users/updateMetadata", map[string]any{
 		"userId":   userId,
@@ -236,9 +514,27 @@ func (k KobbleUsers) ListAll(options *ListUsersOptions) (common.Pagination[User]
 
 // GetActiveProducts retrieves the active product a given user is assigned to.
 //
+// Deprecated: a user can be assigned to several products, use ListActiveProducts instead.
+//
 //   - @param userId - The unique identifier for the user whose active product is being retrieved.
 //   - @returns UserActiveProduct or nil - The active product assigned to the user, or nil if the user has no active product.
 func (k KobbleUsers) GetActiveProducts(userId string) (*UserActiveProduct, error) {
+	result, err := k.ListActiveProducts(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) > 0 {
+		return &result[0], nil
+	}
+
+	return nil, nil
+}
+
+// ListActiveProducts retrieves all the active products a given user is assigned to.
+//
+//   - @param userId - The unique identifier for the user whose active products are being retrieved.
+func (k KobbleUsers) ListActiveProducts(userId string) ([]UserActiveProduct, error) {
 	var result []UserActiveProduct
 	err := k.config.Http.GetJson("/users/listActiveProducts", map[string]string{
 		"userId": userId,
@@ -247,31 +543,85 @@ func (k KobbleUsers) GetActiveProducts(userId string) (*UserActiveProduct, error
 		return nil, err
 	}
 
-	if len(result) > 0 {
-		return &result[0], nil
+	return result, nil
+}
+
+// AssignProduct assigns a user to a product, without going through a payment provider.
+//
+// This is meant for manual deals and trials. The cached permissions and quota usages of the user are dropped.
+//
+//   - @param userId - The unique identifier for the user being assigned.
+//   - @param productId - The unique identifier of the product to assign.
+//   - @param opts - The price and expiration of the assignment. Optional, see AssignProductOptions.
+func (k KobbleUsers) AssignProduct(userId string, productId string, opts *AssignProductOptions) (*UserActiveProduct, error) {
+	payload := map[string]any{
+		"userId":    userId,
+		"productId": productId,
+	}
+	if opts != nil {
+		if opts.PriceID != "" {
+			payload["priceId"] = opts.PriceID
+		}
+		if opts.ExpiresAt != nil {
+			payload["expiresAt"] = opts.ExpiresAt.Format(time.RFC3339)
+		}
 	}
 
-	return nil, nil
+	var result UserActiveProduct
+	err := k.config.Http.PostJson("/users/assignProduct", payload, &result, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	k.InvalidateUser(userId)
+	return &result, nil
 }
 
-func (k KobbleUsers) getCachedUserPerms(userId string) *[]permissions.Permission {
-	key := k.userCacheKey(userId)
-	perms := k.permissionsCache.Get(key)
-	if perms != nil {
-		return perms
+// RevokeProduct removes a user from a product.
+//
+// The cached permissions and quota usages of the user are dropped.
+//
+//   - @param userId - The unique identifier for the user being removed.
+//   - @param productId - The unique identifier of the product to revoke.
+func (k KobbleUsers) RevokeProduct(userId string, productId string) error {
+	err := k.config.Http.PostJson("/users/revokeProduct", map[string]any{
+		"userId":    userId,
+		"productId": productId,
+	}, nil, http.StatusCreated)
+	if err != nil {
+		return err
 	}
 
+	k.InvalidateUser(userId)
 	return nil
 }
 
-func (k KobbleUsers) getCachedUserQuotas(userId string) *[]QuotaUsage {
-	key := k.userCacheKey(userId)
-	quotas := k.quotasCache.Get(key)
-	if quotas != nil {
-		return quotas
+// SetProductExpiry schedules the end of the assignment of a user to a product.
+//
+// The cached permissions and quota usages of the user are dropped, as an expiration in the past ends the assignment right away.
+//
+//   - @param userId - The unique identifier for the user whose assignment is being changed.
+//   - @param productId - The unique identifier of the assigned product.
+//   - @param expiresAt - The time at which the assignment ends, or nil to remove the expiration.
+func (k KobbleUsers) SetProductExpiry(userId string, productId string, expiresAt *time.Time) (*UserActiveProduct, error) {
+	var expires *string
+	if expiresAt != nil {
+		formatted := expiresAt.Format(time.RFC3339)
+		expires = &formatted
 	}
 
-	return nil
+	var result UserActiveProduct
+	err := k.config.Http.PostJson("/users/setProductExpiry", map[string]any{
+		"userId":    userId,
+		"productId": productId,
+		"expiresAt": expires,
+	}, &result, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	k.InvalidateUser(userId)
+	return &result, nil
 }
 
 type ListQuotasOptions struct {
@@ -284,28 +634,44 @@ type ListQuotasOptions struct {
 //   - @param noCache - Set to true to bypass cache and fetch fresh data. Default is false.
 //   - @returns []QuotaUsage - An array of QuotaUsage objects, each representing a quota for the user.
 func (k KobbleUsers) ListQuotas(userId string, opts *ListQuotasOptions) ([]QuotaUsage, error) {
-	quotas := k.getCachedUserQuotas(userId)
-	if opts != nil && !opts.NoCache && quotas != nil {
-		return *quotas, nil
+	return k.listQuotas(context.Background(), userId, opts != nil && opts.NoCache)
+}
+
+// listQuotas returns the quota usages of a user, including the usage recorded by a UsageReporter but not flushed yet.
+func (k KobbleUsers) listQuotas(ctx context.Context, userId string, noCache bool) ([]QuotaUsage, error) {
+	key := k.userCacheKey(userId)
+	if noCache {
+		quotas, err := k.fetchQuotas(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		_ = k.quotasCache.Set(ctx, key, quotas)
+		return k.pendingUsage.apply(userId, quotas), nil
 	}
 
+	quotas, err := k.quotasCache.Get(ctx, key, func(ctx context.Context) ([]QuotaUsage, error) {
+		return k.fetchQuotas(ctx, userId)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return k.pendingUsage.apply(userId, quotas), nil
+}
+
+func (k KobbleUsers) fetchQuotas(ctx context.Context, userId string) ([]QuotaUsage, error) {
 	var result ListApiQuotaResponse
-	err := k.config.Http.GetJson("/users/listQuotas", map[string]string{
+	err := k.config.Http.GetJsonWithContext(ctx, "/users/listQuotas", map[string]string{
 		"userId": userId,
 	}, &result, http.StatusOK)
 	if err != nil {
-		return nil, err
+		return nil, wrapUserBlockedError(userId, err)
 	}
 
 	var quotasUsages []QuotaUsage
 	for _, quota := range result.Quotas {
-		quotasUsages = append(quotasUsages, QuotaUsage{
-			Name:      quota.Name,
-			Usage:     quota.Usage,
-			ExpiresAt: quota.ExpiresAt,
-			Remaining: &quota.Remaining,
-			Limit:     &quota.Limit,
-		})
+		quotasUsages = append(quotasUsages, k.transformApiQuota(quota))
 	}
 
 	return quotasUsages, nil
@@ -318,25 +684,28 @@ type IncrementQuotaOptions struct {
 // IncrementQuotaUsage asynchronously increments the quota usage for a specific user and quota.
 //
 //		This function allows incrementing a user's quota usage by a specified amount, which defaults to 1 if not provided.
+//		The cached quota usages of the user are dropped.
 //
 //	 - @param userId - The unique identifier for the user whose quota is being incremented.
 //	 - @param quotaName - The name of the quota to increment.
 //	 - @param incrementBy - The amount by which to increment the quota usage. Optional and defaults to 1.
-func (k KobbleUsers) IncrementQuotaUsage(userId string, quotaName string, opts *IncrementQuotaOptions) error {
+//	 - @returns QuotaUsage - The usage of the quota after the increment.
+func (k KobbleUsers) IncrementQuotaUsage(userId string, quotaName string, opts *IncrementQuotaOptions) (QuotaUsage, error) {
 	inc := 1
 	if opts != nil {
 		inc = opts.IncrementBy
 	}
-	err := k.config.Http.PostJson("/quotas/incrementUsage", map[string]any{
+	return k.incrementQuotaUsage(context.Background(), userId, quotaName, inc)
+}
+
+func (k KobbleUsers) incrementQuotaUsage(ctx context.Context, userId string, quotaName string, incrementBy int) (QuotaUsage, error) {
+	var result ApiQuota
+	err := k.config.Http.PostJsonWithContext(ctx, "/quotas/incrementUsage", map[string]any{
 		"userId":      userId,
 		"quotaName":   quotaName,
-		"incrementBy": inc,
-	}, nil, http.StatusCreated)
-	if err != nil {
-		return err
-	}
-
-	return nil
+		"incrementBy": incrementBy,
+	}, &result, http.StatusCreated)
+	return k.changedQuotaUsage(ctx, userId, quotaName, result, err)
 }
 
 type DecrementQuotaOptions struct {
@@ -346,11 +715,13 @@ type DecrementQuotaOptions struct {
 // DecrementQuotaUsage asynchronously decrements the quota usage for a specific user and quota.
 //
 //		This function allows decrementing a user's quota usage by a specified amount, which defaults to 1 if not provided.
+//		The cached quota usages of the user are dropped.
 //
 //	 - @param userId - The unique identifier for the user whose quota is being decremented.
 //	 - @param quotaName - The name of the quota to decrement.
 //	 - @param decrementBy - The amount by which to decrement the quota usage. Optional and defaults to 1.
-func (k KobbleUsers) DecrementQuotaUsage(userId string, quotaName string, opts *DecrementQuotaOptions) error {
+//	 - @returns QuotaUsage - The usage of the quota after the decrement.
+func (k KobbleUsers) DecrementQuotaUsage(userId string, quotaName string, opts *DecrementQuotaOptions) (QuotaUsage, error) {
 	dec := 1
 	if opts != nil {
 		dec = opts.DecrementBy
@@ -360,36 +731,53 @@ func (k KobbleUsers) DecrementQuotaUsage(userId string, quotaName string, opts *
 	if dec > 0 {
 		incrementBy = -dec
 	}
-
-	err := k.config.Http.PostJson("/quotas/incrementUsage", map[string]any{
-		"userId":      userId,
-		"quotaName":   quotaName,
-		"incrementBy": incrementBy,
-	}, nil, http.StatusCreated)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return k.incrementQuotaUsage(context.Background(), userId, quotaName, incrementBy)
 }
 
 // SetQuotaUsage asynchronously set the quota usage for a given user to a given number.
 //
 //		Unlike incrementQuotaUsage and decrementQuotaUsage, this will set the usage to the specific number.
+//		The cached quota usages of the user are dropped.
 //
 //	 - @param userId - The unique identifier for the user whose quota is being changed.
 //	 - @param quotaName - The name of the quota to change.
 //	 - @param usage - The new usage you want to set.
-func (k KobbleUsers) SetQuotaUsage(userId string, quotaName string, usage int) error {
-	err := k.config.Http.PostJson("/quotas/setUsage", map[string]any{
+//	 - @returns QuotaUsage - The usage of the quota after the change.
+func (k KobbleUsers) SetQuotaUsage(userId string, quotaName string, usage int) (QuotaUsage, error) {
+	return k.setQuotaUsage(context.Background(), userId, quotaName, usage)
+}
+
+func (k KobbleUsers) setQuotaUsage(ctx context.Context, userId string, quotaName string, usage int) (QuotaUsage, error) {
+	var result ApiQuota
+	err := k.config.Http.PostJsonWithContext(ctx, "/quotas/setUsage", map[string]any{
 		"userId":    userId,
 		"quotaName": quotaName,
 		"usage":     usage,
-	}, nil, http.StatusCreated)
+	}, &result, http.StatusCreated)
+	return k.changedQuotaUsage(ctx, userId, quotaName, result, err)
+}
+
+// changedQuotaUsage returns the usage of a quota after a change, given the response of the Kobble API, and drops
+// the cached quota usages of the user. They are dropped rather than updated, as the responses of concurrent changes
+// can arrive out of order.
+//
+// Older versions of the Kobble API answer without a body although the change was applied: the usage is then fetched,
+// and when that fails, only the Name of the returned usage is set.
+func (k KobbleUsers) changedQuotaUsage(ctx context.Context, userId string, quotaName string, result ApiQuota, err error) (QuotaUsage, error) {
+	if err != nil && !errors.Is(err, utils.ErrEmptyResponse) {
+		return QuotaUsage{}, err
+	}
+
+	_ = k.quotasCache.Delete(context.WithoutCancel(ctx), k.userCacheKey(userId))
+	if err == nil {
+		return k.transformApiQuota(result), nil
+	}
+
+	usage, err := k.getFreshQuotaUsage(ctx, userId, quotaName)
 	if err != nil {
-		return err
+		return QuotaUsage{Name: quotaName}, nil
 	}
-	return nil
+	return usage, nil
 }
 
 // GetQuotaUsage retrieves the quota usage for a given user based on the product assigned to them.
@@ -420,17 +808,33 @@ type ListPermissionsOptions struct {
 //   - @param userId - The unique identifier for the user whose permissions are being retrieved.
 //   - @param noCache - Set to true to bypass cache and fetch fresh data. Default is false.
 func (k KobbleUsers) ListPermissions(userId string, opts *ListPermissionsOptions) ([]permissions.Permission, error) {
-	perms := k.getCachedUserPerms(userId)
-	if opts != nil && !opts.NoCache && perms != nil {
-		return *perms, nil
+	return k.listPermissions(context.Background(), userId, opts != nil && opts.NoCache)
+}
+
+func (k KobbleUsers) listPermissions(ctx context.Context, userId string, noCache bool) ([]permissions.Permission, error) {
+	key := k.userCacheKey(userId)
+	if noCache {
+		perms, err := k.fetchPermissions(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		_ = k.permissionsCache.Set(ctx, key, perms)
+		return perms, nil
 	}
 
+	return k.permissionsCache.Get(ctx, key, func(ctx context.Context) ([]permissions.Permission, error) {
+		return k.fetchPermissions(ctx, userId)
+	})
+}
+
+func (k KobbleUsers) fetchPermissions(ctx context.Context, userId string) ([]permissions.Permission, error) {
 	var result []permissions.Permission
-	err := k.config.Http.GetJson("/users/listPermissions", map[string]string{
+	err := k.config.Http.GetJsonWithContext(ctx, "/users/listPermissions", map[string]string{
 		"userId": userId,
 	}, &result, http.StatusOK)
 	if err != nil {
-		return nil, err
+		return nil, wrapUserBlockedError(userId, err)
 	}
 
 	return result, nil
@@ -438,70 +842,141 @@ func (k KobbleUsers) ListPermissions(userId string, opts *ListPermissionsOptions
 
 type HasRemainingQuotaOptions struct {
 	NoCache bool
+	Mode    CheckMode
 }
 
 // HasRemainingQuota checks if a user has remaining credit for all specified quota(s).
 //
+// Set the mode to ModeAny to only require remaining credit on at least one of the quotas.
+//
 //   - @param userId - The unique identifier for the user whose quotas are being checked.
 //   - @param quotaNames - The names of the quotas to check. Can be a single name or an array of names.
 //   - @param noCache - Set to true to bypass cache and fetch fresh data. Default is false.
+//   - @param mode - Whether all (ModeAll) or any (ModeAny) of the quotas must have remaining credit. Default is ModeAll.
 func (k KobbleUsers) HasRemainingQuota(userId string, quotaNames []string, opts *HasRemainingQuotaOptions) (bool, error) {
+	result, err := k.CheckRemainingQuota(userId, quotaNames, opts)
+	if err != nil {
+		return false, err
+	}
+
+	return result.Granted, nil
+}
+
+// CheckRemainingQuota works like HasRemainingQuota but also reports which quotas are exhausted.
+//
+//   - @param userId - The unique identifier for the user whose quotas are being checked.
+//   - @param quotaNames - The names of the quotas to check.
+//   - @param noCache - Set to true to bypass cache and fetch fresh data. Default is false.
+//   - @param mode - Whether all (ModeAll) or any (ModeAny) of the quotas must have remaining credit. Default is ModeAll.
+func (k KobbleUsers) CheckRemainingQuota(userId string, quotaNames []string, opts *HasRemainingQuotaOptions) (QuotaCheckResult, error) {
 	var listQuotaOpts *ListQuotasOptions = nil
+	var mode CheckMode
 	if opts != nil {
 		listQuotaOpts = &ListQuotasOptions{
 			NoCache: opts.NoCache,
 		}
+		mode = opts.Mode
 	}
 	quotas, err := k.ListQuotas(userId, listQuotaOpts)
 	if err != nil {
-		return false, err
+		return QuotaCheckResult{}, err
 	}
 
+	return evaluateQuotas(quotas, quotaNames, mode), nil
+}
+
+func evaluateQuotas(quotas []QuotaUsage, quotaNames []string, mode CheckMode) QuotaCheckResult {
+	exhausted := []string{}
 	for _, quotaName := range quotaNames {
+		hasRemaining := false
 		for _, quota := range quotas {
 			if quota.Name == quotaName && quota.Remaining != nil && *quota.Remaining > 0 {
-				return true, nil
+				hasRemaining = true
+				break
 			}
 		}
+
+		if !hasRemaining {
+			exhausted = append(exhausted, quotaName)
+		}
 	}
 
-	return false, nil
+	return QuotaCheckResult{
+		Granted:         mode.isSatisfied(len(quotaNames), len(exhausted)),
+		ExhaustedQuotas: exhausted,
+	}
 }
 
 type HasPermissionOptions struct {
 	NoCache bool
+	Mode    CheckMode
 }
 
 // HasPermission checks if a user has all permissions specified as arguments.
 //
+// Set the mode to ModeAny to only require at least one of the permissions.
+//
 //   - @param userId - The unique identifier for the user whose permissions are being checked.
 //   - @param permissionNames - The names of the permission(s) to check. Can be a single permission name or an array of names.
 //   - @param noCache - Set to true to bypass cache and fetch fresh data. Default is false.
+//   - @param mode - Whether all (ModeAll) or any (ModeAny) of the permissions are required. Default is ModeAll.
 func (k KobbleUsers) HasPermission(userId string, permissionNames []string, opts *HasPermissionOptions) (bool, error) {
+	result, err := k.CheckPermissions(userId, permissionNames, opts)
+	if err != nil {
+		return false, err
+	}
+
+	return result.Granted, nil
+}
+
+// CheckPermissions works like HasPermission but also reports which permissions are missing.
+//
+//   - @param userId - The unique identifier for the user whose permissions are being checked.
+//   - @param permissionNames - The names of the permissions to check.
+//   - @param noCache - Set to true to bypass cache and fetch fresh data. Default is false.
+//   - @param mode - Whether all (ModeAll) or any (ModeAny) of the permissions are required. Default is ModeAll.
+func (k KobbleUsers) CheckPermissions(userId string, permissionNames []string, opts *HasPermissionOptions) (PermissionCheckResult, error) {
 	var listPermissionOpts *ListPermissionsOptions = nil
+	var mode CheckMode
 	if opts != nil {
 		listPermissionOpts = &ListPermissionsOptions{
 			NoCache: opts.NoCache,
 		}
+		mode = opts.Mode
 	}
 	perms, err := k.ListPermissions(userId, listPermissionOpts)
 	if err != nil {
-		return false, err
+		return PermissionCheckResult{}, err
 	}
 
+	return evaluatePermissions(perms, permissionNames, mode), nil
+}
+
+func evaluatePermissions(perms []permissions.Permission, permissionNames []string, mode CheckMode) PermissionCheckResult {
+	missing := []string{}
 	for _, permName := range permissionNames {
+		found := false
 		for _, perm := range perms {
 			if perm.Name == permName {
-				return true, nil
+				found = true
+				break
 			}
 		}
+
+		if !found {
+			missing = append(missing, permName)
+		}
 	}
 
-	return false, nil
+	return PermissionCheckResult{
+		Granted:            mode.isSatisfied(len(permissionNames), len(missing)),
+		MissingPermissions: missing,
+	}
 }
 
 type IsAllowedOptions struct {
 	NoCache bool
+	Mode    CheckMode
 }
 
 // IsAllowed this function is a helper to check if a user has all permissions and quotas specified in the payload.
@@ -509,51 +984,79 @@ type IsAllowedOptions struct {
 //			If both permissionNames and quotaNames are provided, the user must have all permissions and quotas to be allowed.
 //			If only permissionNames are provided, the user must have all permissions to be allowed.
 //			If only quotaNames are provided, the user must have all quotas to be allowed.
+//			With ModeAny, a single permission and a single quota with remaining credit are enough.
+//			If the user is blocked, an error matching ErrUserBlocked is returned.
 //
 //	 - @param userId - The unique identifier for the user whose quotas are being checked.
 //	 - @param payload - The payload containing the permission and quota names to check.
 //	 - @param payload.permissionNames - The names of the permissions to check.
 //	 - @param payload.quotaNames - The names of the quotas to check.
 //	 - @param noCache - Set to true to bypass cache and fetch fresh data. Default is false.
+//	 - @param mode - Whether all (ModeAll) or any (ModeAny) of the names are required. Default is ModeAll.
 func (k KobbleUsers) IsAllowed(userId string, payload IsAllowedPayload, opts *IsAllowedOptions) (bool, error) {
+	result, err := k.CheckAllowed(userId, payload, opts)
+	if err != nil {
+		return false, err
+	}
+
+	return result.Allowed, nil
+}
+
+// CheckAllowed works like IsAllowed but also reports which permissions are missing and which quotas are exhausted.
+//
+//   - @param userId - The unique identifier for the user whose quotas are being checked.
+//   - @param payload - The payload containing the permission and quota names to check.
+//   - @param noCache - Set to true to bypass cache and fetch fresh data. Default is false.
+//   - @param mode - Whether all (ModeAll) or any (ModeAny) of the names are required. Default is ModeAll.
+func (k KobbleUsers) CheckAllowed(userId string, payload IsAllowedPayload, opts *IsAllowedOptions) (IsAllowedResult, error) {
 	var hasPermissionOpts *HasPermissionOptions = nil
 	var hasRemainingQuotaOpts *HasRemainingQuotaOptions = nil
 	if opts != nil {
 		hasPermissionOpts = &HasPermissionOptions{
 			NoCache: opts.NoCache,
+			Mode:    opts.Mode,
 		}
 		hasRemainingQuotaOpts = &HasRemainingQuotaOptions{
 			NoCache: opts.NoCache,
+			Mode:    opts.Mode,
 		}
 	}
 
-	if len(payload.PermissionNames) > 0 && len(payload.QuotaNames) > 0 {
-		hasPermission, err := k.HasPermission(userId, payload.PermissionNames, hasPermissionOpts)
-		if err != nil {
-			return false, err
-		}
+	result := IsAllowedResult{
+		MissingPermissions: []string{},
+		ExhaustedQuotas:    []string{},
+	}
+	if len(payload.PermissionNames) == 0 && len(payload.QuotaNames) == 0 {
+		return result, nil
+	}
 
-		hasQuota, err := k.HasRemainingQuota(userId, payload.QuotaNames, hasRemainingQuotaOpts)
+	result.Allowed = true
+	if len(payload.PermissionNames) > 0 {
+		permissionCheck, err := k.CheckPermissions(userId, payload.PermissionNames, hasPermissionOpts)
 		if err != nil {
-			return false, err
+			return IsAllowedResult{}, err
 		}
 
-		return hasPermission && hasQuota, nil
-	}
-
-	if len(payload.PermissionNames) > 0 {
-		return k.HasPermission(userId, payload.PermissionNames, hasPermissionOpts)
+		result.Allowed = permissionCheck.Granted
+		result.MissingPermissions = permissionCheck.MissingPermissions
 	}
 
 	if len(payload.QuotaNames) > 0 {
-		return k.HasRemainingQuota(userId, payload.QuotaNames, hasRemainingQuotaOpts)
+		quotaCheck, err := k.CheckRemainingQuota(userId, payload.QuotaNames, hasRemainingQuotaOpts)
+		if err != nil {
+			return IsAllowedResult{}, err
+		}
+
+		result.Allowed = result.Allowed && quotaCheck.Granted
+		result.ExhaustedQuotas = quotaCheck.ExhaustedQuotas
 	}
 
-	return false, nil
+	return result, nil
 }
 
 type IsForbiddenOptions struct {
 	NoCache bool
+	Mode    CheckMode
 }
 
 // IsForbidden this function is a helper to check if a user is forbidden from performing an action.
@@ -565,11 +1068,13 @@ type IsForbiddenOptions struct {
 //	 - @param payload.permissionNames - The names of the permissions to check.
 //	 - @param payload.quotaNames - The names of the quotas to check.
 //	 - @param noCache - Set to true to bypass cache and fetch fresh data. Default is false.
+//	 - @param mode - Whether all (ModeAll) or any (ModeAny) of the names are required. Default is ModeAll.
 func (k KobbleUsers) IsForbidden(userId string, payload IsAllowedPayload, opts *IsForbiddenOptions) (bool, error) {
 	var isAllowedOpts *IsAllowedOptions = nil
 	if opts != nil {
 		isAllowedOpts = &IsAllowedOptions{
 			NoCache: opts.NoCache,
+			Mode:    opts.Mode,
 		}
 	}
 	isAllowed, err := k.IsAllowed(userId, payload, isAllowedOpts)
@@ -0,0 +1,283 @@
+package users
+
+import (
+	"encoding/json"
+	"github.com/kobble-io/go-admin/internal/testutil"
+	"github.com/kobble-io/go-admin/permissions"
+	"github.com/kobble-io/go-admin/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type callCounter struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (c *callCounter) add(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[path]++
+}
+
+func (c *callCounter) get(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[path]
+}
+
+func newTestUsers(t *testing.T, config Config) (*KobbleUsers, *callCounter) {
+	t.Helper()
+
+	counter := &callCounter{calls: map[string]int{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter.add(r.URL.Path)
+		switch r.URL.Path {
+		case "/users/listPermissions":
+			_ = json.NewEncoder(w).Encode([]permissions.Permission{{ID: "p1", Name: "read"}})
+		case "/users/listQuotas":
+			_ = json.NewEncoder(w).Encode(ListApiQuotaResponse{Quotas: []ApiQuota{
+				{Name: "credits", Usage: 1, Remaining: 9, Limit: 10, ExpiresAt: time.Now().Add(time.Hour)},
+			}})
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	config.Http = utils.NewHttpClient(utils.HttpClientConfig{BaseURL: server.URL})
+	k := NewKobbleUsers(config)
+	t.Cleanup(k.Close)
+	return k, counter
+}
+
+func TestUserCachesLifetime(t *testing.T) {
+	const ttl = 100 * time.Millisecond
+
+	calls := map[string]func(k *KobbleUsers) error{
+		"/users/listPermissions": func(k *KobbleUsers) error {
+			_, err := k.ListPermissions("u1", nil)
+			return err
+		},
+		"/users/listQuotas": func(k *KobbleUsers) error {
+			_, err := k.ListQuotas("u1", nil)
+			return err
+		},
+	}
+
+	tests := []struct {
+		name       string
+		staleTtl   time.Duration
+		waits      []time.Duration
+		invalidate bool
+		wantCalls  int
+	}{
+		{
+			name:      "repeated calls within the ttl are served from the cache",
+			waits:     []time.Duration{0, 0, 0, 20 * time.Millisecond},
+			wantCalls: 1,
+		},
+		{
+			name:      "a call past the ttl fetches again",
+			waits:     []time.Duration{0, 150 * time.Millisecond, 0},
+			wantCalls: 2,
+		},
+		{
+			name:       "a call after InvalidateUser fetches again",
+			waits:      []time.Duration{0, 0},
+			invalidate: true,
+			wantCalls:  2,
+		},
+		{
+			name:      "a call past the ttl with a stale ttl refreshes once in the background",
+			staleTtl:  200 * time.Millisecond,
+			waits:     []time.Duration{0, 150 * time.Millisecond, 0},
+			wantCalls: 2,
+		},
+		{
+			name:      "a call past the ttl and the stale ttl fetches again",
+			staleTtl:  200 * time.Millisecond,
+			waits:     []time.Duration{0, 400 * time.Millisecond},
+			wantCalls: 2,
+		},
+	}
+
+	for path, call := range calls {
+		for _, tt := range tests {
+			t.Run(path+"/"+tt.name, func(t *testing.T) {
+				k, counter := newTestUsers(t, Config{
+					PermissionsCacheTtl: ttl,
+					QuotasCacheTtl:      ttl,
+					StaleCacheTtl:       tt.staleTtl,
+				})
+
+				for i, wait := range tt.waits {
+					time.Sleep(wait)
+					if tt.invalidate && i > 0 {
+						k.InvalidateUser("u1")
+					}
+					if err := call(k); err != nil {
+						t.Fatalf("call error = %v", err)
+					}
+				}
+
+				// Let background refreshes complete.
+				time.Sleep(50 * time.Millisecond)
+				if got := counter.get(path); got != tt.wantCalls {
+					t.Errorf("calls to %s = %d, want %d", path, got, tt.wantCalls)
+				}
+			})
+		}
+	}
+}
+
+func TestUserCachesDefaultTtl(t *testing.T) {
+	permissionsCache := testutil.NewRecordingCache[[]permissions.Permission]()
+	quotasCache := testutil.NewRecordingCache[[]QuotaUsage]()
+	k, counter := newTestUsers(t, Config{PermissionsCache: permissionsCache, QuotasCache: quotasCache})
+
+	for i := 0; i < 3; i++ {
+		if _, err := k.ListPermissions("u1", nil); err != nil {
+			t.Fatalf("ListPermissions() error = %v", err)
+		}
+		if _, err := k.ListQuotas("u1", nil); err != nil {
+			t.Fatalf("ListQuotas() error = %v", err)
+		}
+	}
+
+	for _, path := range []string{"/users/listPermissions", "/users/listQuotas"} {
+		if got := counter.get(path); got != 1 {
+			t.Errorf("calls to %s = %d, want 1", path, got)
+		}
+	}
+	if got := permissionsCache.Ttl("user:u1"); got != time.Minute {
+		t.Errorf("permissions cached for %s, want %s", got, time.Minute)
+	}
+	if got := quotasCache.Ttl("user:u1"); got != time.Minute {
+		t.Errorf("quotas cached for %s, want %s", got, time.Minute)
+	}
+}
+
+func TestQuotaChangeResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		listStatus  int
+		wantUsage   int
+		wantLimit   bool
+		wantListing int
+	}{
+		{
+			name:        "the usage returned by the Kobble API is used and the cached usages are dropped",
+			body:        `{"name":"credits","usage":5,"remaining":5,"limit":10}`,
+			listStatus:  http.StatusOK,
+			wantUsage:   5,
+			wantLimit:   true,
+			wantListing: 2,
+		},
+		{
+			name:        "an empty response is a success and the usage is fetched",
+			listStatus:  http.StatusOK,
+			wantUsage:   1,
+			wantLimit:   true,
+			wantListing: 2,
+		},
+		{
+			name:        "an empty response is a success even when the usage cannot be fetched",
+			listStatus:  http.StatusInternalServerError,
+			wantListing: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var listings atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/quotas/incrementUsage":
+					w.WriteHeader(http.StatusCreated)
+					_, _ = w.Write([]byte(tt.body))
+				case "/users/listQuotas":
+					// The first listing fills the cache, later ones may fail.
+					if listings.Add(1) > 1 && tt.listStatus != http.StatusOK {
+						w.WriteHeader(tt.listStatus)
+						return
+					}
+					_ = json.NewEncoder(w).Encode(ListApiQuotaResponse{Quotas: []ApiQuota{
+						{Name: "credits", Usage: 1, Remaining: 9, Limit: 10},
+					}})
+				default:
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+			}))
+			defer server.Close()
+
+			k := NewKobbleUsers(Config{Http: utils.NewHttpClient(utils.HttpClientConfig{BaseURL: server.URL})})
+			defer k.Close()
+
+			if _, err := k.ListQuotas("u1", nil); err != nil {
+				t.Fatalf("ListQuotas() error = %v", err)
+			}
+
+			usage, err := k.IncrementQuotaUsage("u1", "credits", nil)
+			if err != nil {
+				t.Fatalf("IncrementQuotaUsage() error = %v", err)
+			}
+			if usage.Name != "credits" || usage.Usage != tt.wantUsage || (usage.Limit != nil) != tt.wantLimit {
+				t.Errorf("IncrementQuotaUsage() = %+v, want usage %d", usage, tt.wantUsage)
+			}
+
+			_, _ = k.ListQuotas("u1", nil)
+			if got := int(listings.Load()); got != tt.wantListing {
+				t.Errorf("calls to /users/listQuotas = %d, want %d", got, tt.wantListing)
+			}
+		})
+	}
+}
+
+func TestInvalidateAllQuotas(t *testing.T) {
+	t.Run("in-memory cache is cleared", func(t *testing.T) {
+		k, counter := newTestUsers(t, Config{})
+
+		for _, userId := range []string{"u1", "u2", "u1", "u2"} {
+			if _, err := k.ListQuotas(userId, nil); err != nil {
+				t.Fatalf("ListQuotas() error = %v", err)
+			}
+		}
+		k.InvalidateAllQuotas()
+		_, _ = k.ListQuotas("u1", nil)
+		_, _ = k.ListQuotas("u2", nil)
+
+		if got := counter.get("/users/listQuotas"); got != 4 {
+			t.Errorf("calls to /users/listQuotas = %d, want 4", got)
+		}
+	})
+
+	t.Run("shared cache keeps its keys across processes", func(t *testing.T) {
+		store := testutil.NewMapStore()
+		shared := func() Config {
+			return Config{QuotasCache: utils.NewKeyValueCache[[]QuotaUsage](store, "quotas:", 0)}
+		}
+		first, counter := newTestUsers(t, shared())
+		second := NewKobbleUsers(Config{Http: first.config.Http, QuotasCache: shared().QuotasCache})
+		t.Cleanup(second.Close)
+
+		_, _ = first.ListQuotas("u1", nil)
+		_, _ = second.ListQuotas("u1", nil)
+		if got := counter.get("/users/listQuotas"); got != 1 {
+			t.Fatalf("calls to /users/listQuotas = %d, want the second process to read the shared cache", got)
+		}
+
+		first.InvalidateAllQuotas()
+		second.InvalidateUser("u1")
+		_, _ = first.ListQuotas("u1", nil)
+		if got := counter.get("/users/listQuotas"); got != 2 {
+			t.Errorf("calls to /users/listQuotas = %d, want InvalidateUser on one process to drop the entry of the other", got)
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"container/list"
+	"context"
 	"sync"
 	"time"
 )
@@ -11,58 +13,187 @@ type CacheKey[T any] struct {
 	ExpiresAt *int64
 }
 
+// Cache is the storage used by the SDK to cache data fetched from the Kobble API.
+//
+// The default implementation is MemoryCache, which is local to the process.
+// Deployments running several instances can share a cache by providing their own implementation,
+// for instance a KeyValueCache backed by Redis or memcached.
+//
+//   - Get returns nil (and no error) when the key is missing or expired.
+//   - Set stores data under key for the given ttl. A ttl of zero lets the implementation pick its default.
+//   - Delete removes the entry stored under key, if any.
+type Cache[T any] interface {
+	Get(ctx context.Context, key string) (*T, error)
+	Set(ctx context.Context, key string, data T, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// CacheConfig is the configuration of a MemoryCache.
+//
+//   - DefaultTtl is the time to live applied to entries set without an explicit ttl. Zero means no expiration.
+//   - MaxEntries is the maximum number of entries kept in the cache. When it is reached, the least recently used entry is evicted. Zero means unbounded.
+//   - CleanupInterval is the interval at which a background janitor sweeps expired entries. Zero disables the janitor.
 type CacheConfig struct {
-	DefaultTtl *time.Duration
+	DefaultTtl      time.Duration
+	MaxEntries      int
+	CleanupInterval time.Duration
+}
+
+type cacheEntry[T any] struct {
+	key   string
+	value CacheKey[T]
 }
 
-type Cache[T any] struct {
-	data   map[string]CacheKey[T]
+// MemoryCache is an in-memory Cache with optional expiration, LRU eviction and background expiry.
+type MemoryCache[T any] struct {
+	data   map[string]*list.Element
+	lru    *list.List
 	config CacheConfig
 	mu     sync.Mutex
+	stop   chan struct{}
+	once   sync.Once
 }
 
-func NewCache[T any](config CacheConfig) *Cache[T] {
-	return &Cache[T]{
-		data:   make(map[string]CacheKey[T]),
+func NewMemoryCache[T any](config CacheConfig) *MemoryCache[T] {
+	c := &MemoryCache[T]{
+		data:   make(map[string]*list.Element),
+		lru:    list.New(),
 		config: config,
+		stop:   make(chan struct{}),
 	}
+
+	if config.CleanupInterval > 0 {
+		go c.janitor(config.CleanupInterval)
+	}
+
+	return c
+}
+
+func (c *MemoryCache[T]) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.DeleteExpired()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Stop terminates the background janitor, if any. The cache remains usable afterward.
+func (c *MemoryCache[T]) Stop() {
+	c.once.Do(func() {
+		close(c.stop)
+	})
 }
 
-func (c *Cache[T]) Get(key string) *T {
+func (c *MemoryCache[T]) isExpired(entry CacheKey[T], now int64) bool {
+	return entry.ExpiresAt != nil && now > *entry.ExpiresAt
+}
+
+func (c *MemoryCache[T]) removeElement(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.data, el.Value.(*cacheEntry[T]).key)
+}
+
+func (c *MemoryCache[T]) Get(_ context.Context, key string) (*T, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	ckey, exists := c.data[key]
+	el, exists := c.data[key]
 	if !exists {
-		return nil
+		return nil, nil
 	}
 
-	now := time.Now().UnixMilli()
-	if ckey.ExpiresAt != nil && now > *ckey.ExpiresAt {
-		delete(c.data, key)
-		return nil
+	entry := el.Value.(*cacheEntry[T])
+	if c.isExpired(entry.value, time.Now().UnixMilli()) {
+		c.removeElement(el)
+		return nil, nil
 	}
 
-	return &ckey.Data
+	c.lru.MoveToFront(el)
+	data := entry.value.Data
+	return &data, nil
 }
 
-func (c *Cache[T]) Set(key string, data T, ttl *time.Duration) {
+// Set stores data under key for the given ttl.
+// A ttl of zero (or less) falls back to the DefaultTtl of the cache.
+func (c *MemoryCache[T]) Set(_ context.Context, key string, data T, ttl time.Duration) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if ttl <= 0 {
+		ttl = c.config.DefaultTtl
+	}
+
 	now := time.Now()
 	var expiresAt *int64
-	if ttl != nil {
-		expires := now.Add(*ttl * time.Second).UnixMilli()
-		expiresAt = &expires
-	} else if c.config.DefaultTtl != nil {
-		expires := now.Add(*c.config.DefaultTtl).UnixMilli()
+	if ttl > 0 {
+		expires := now.Add(ttl).UnixMilli()
 		expiresAt = &expires
 	}
 
-	c.data[key] = CacheKey[T]{
+	value := CacheKey[T]{
 		Data:      data,
 		CreatedAt: now.UnixMilli(),
 		ExpiresAt: expiresAt,
 	}
+
+	if el, exists := c.data[key]; exists {
+		el.Value.(*cacheEntry[T]).value = value
+		c.lru.MoveToFront(el)
+		return nil
+	}
+
+	c.data[key] = c.lru.PushFront(&cacheEntry[T]{key: key, value: value})
+	if c.config.MaxEntries > 0 && c.lru.Len() > c.config.MaxEntries {
+		c.removeElement(c.lru.Back())
+	}
+	return nil
+}
+
+// Delete removes the entry stored under key, if any.
+func (c *MemoryCache[T]) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, exists := c.data[key]; exists {
+		c.removeElement(el)
+	}
+	return nil
+}
+
+// DeleteExpired removes every expired entry from the cache.
+func (c *MemoryCache[T]) DeleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	for el := c.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if c.isExpired(el.Value.(*cacheEntry[T]).value, now) {
+			c.removeElement(el)
+		}
+		el = prev
+	}
+}
+
+// Clear removes every entry from the cache.
+func (c *MemoryCache[T]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.data = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// Len returns the number of entries currently held by the cache, including expired entries not yet swept.
+func (c *MemoryCache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
 }
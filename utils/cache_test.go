@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheTtl(t *testing.T) {
+	tests := []struct {
+		name       string
+		defaultTtl time.Duration
+		ttl        time.Duration
+		wait       time.Duration
+		wantHit    bool
+	}{
+		{name: "explicit ttl, read before expiry", ttl: 100 * time.Millisecond, wait: 20 * time.Millisecond, wantHit: true},
+		{name: "explicit ttl, read after expiry", ttl: 50 * time.Millisecond, wait: 100 * time.Millisecond, wantHit: false},
+		{name: "default ttl, read before expiry", defaultTtl: 100 * time.Millisecond, wait: 20 * time.Millisecond, wantHit: true},
+		{name: "default ttl, read after expiry", defaultTtl: 50 * time.Millisecond, wait: 100 * time.Millisecond, wantHit: false},
+		{name: "explicit ttl overrides the default ttl", defaultTtl: 50 * time.Millisecond, ttl: 200 * time.Millisecond, wait: 100 * time.Millisecond, wantHit: true},
+		{name: "no ttl never expires", wait: 50 * time.Millisecond, wantHit: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cache := NewMemoryCache[string](CacheConfig{DefaultTtl: tt.defaultTtl})
+			defer cache.Stop()
+
+			if err := cache.Set(ctx, "key", "value", tt.ttl); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			time.Sleep(tt.wait)
+
+			got, err := cache.Get(ctx, "key")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if (got != nil) != tt.wantHit {
+				t.Errorf("Get() hit = %v, want %v", got != nil, tt.wantHit)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache[int](CacheConfig{MaxEntries: 2, CleanupInterval: 10 * time.Millisecond})
+	defer cache.Stop()
+
+	_ = cache.Set(ctx, "a", 1, 0)
+	_ = cache.Set(ctx, "b", 2, 0)
+	_, _ = cache.Get(ctx, "a")
+	_ = cache.Set(ctx, "c", 3, 0)
+
+	if got, _ := cache.Get(ctx, "b"); got != nil {
+		t.Errorf("Get(b) = %v, want the least recently used entry to be evicted", *got)
+	}
+	if got, _ := cache.Get(ctx, "a"); got == nil {
+		t.Errorf("Get(a) = nil, want the recently used entry to be kept")
+	}
+
+	_ = cache.Set(ctx, "d", 4, 20*time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+	if got := cache.Len(); got != 1 {
+		t.Errorf("Len() = %d after the janitor swept, want 1", got)
+	}
+}
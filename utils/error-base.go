@@ -19,3 +19,7 @@ func NewErrorBase(name, message string, cause error) *ErrorBase {
 func (e *ErrorBase) Error() string {
 	return fmt.Sprintf("%s: %s", e.Name, e.Message)
 }
+
+func (e *ErrorBase) Unwrap() error {
+	return e.Cause
+}
@@ -2,12 +2,16 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 )
 
 type HttpClientConfig struct {
@@ -50,13 +54,76 @@ func (c *HttpClient) makeURL(path string, params map[string]string) (string, err
 	return finalURL.String(), nil
 }
 
+// PageParams returns the query parameters of a paginated request. The page defaults to 1 and the limit to 50.
+func PageParams(page int, limit int) map[string]string {
+	p, l := 1, 50
+	if page > p {
+		p = page
+	}
+
+	if limit != l && limit > 0 {
+		l = limit
+	}
+
+	return map[string]string{
+		"page":  strconv.Itoa(p),
+		"limit": strconv.Itoa(l),
+	}
+}
+
+// ErrEmptyResponse is returned when the Kobble API answers with the expected status but without a body to decode.
+var ErrEmptyResponse = errors.New("empty response body")
+
+func decodeResponse(body io.Reader, result any) error {
+	err := json.NewDecoder(body).Decode(&result)
+	if err == io.EOF {
+		return ErrEmptyResponse
+	}
+	return err
+}
+
+// HttpError is returned when the Kobble API answers with an unexpected status code.
+type HttpError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HttpError) Error() string {
+	return fmt.Sprintf("error: %s", e.Body)
+}
+
+// unknownRoutePattern matches the body of the 404 answered for a route that does not exist, e.g. "Cannot POST /users/search".
+var unknownRoutePattern = regexp.MustCompile(`Cannot (GET|POST|PUT|PATCH|DELETE) /`)
+
+// IsUnsupportedEndpoint reports whether err means the Kobble API does not expose the requested endpoint.
+//
+// A 404 only means so when its body reports an unknown route, as the Kobble API also answers 404 for unknown users or quotas.
+func IsUnsupportedEndpoint(err error) bool {
+	var httpErr *HttpError
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+
+	switch httpErr.StatusCode {
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	case http.StatusNotFound:
+		return unknownRoutePattern.MatchString(httpErr.Body)
+	}
+	return false
+}
+
 func (c *HttpClient) GetJson(path string, params map[string]string, result any, expectedStatus int) error {
+	return c.GetJsonWithContext(context.Background(), path, params, result, expectedStatus)
+}
+
+func (c *HttpClient) GetJsonWithContext(ctx context.Context, path string, params map[string]string, result any, expectedStatus int) error {
 	fullURL, err := c.makeURL(path, params)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodGet, fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
 	if err != nil {
 		return err
 	}
@@ -73,16 +140,20 @@ func (c *HttpClient) GetJson(path string, params map[string]string, result any,
 
 	if resp.StatusCode != expectedStatus {
 		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("error: %s", string(bodyBytes))
+		return &HttpError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
 	if result == nil {
 		return nil
 	}
-	return json.NewDecoder(resp.Body).Decode(&result)
+	return decodeResponse(resp.Body, result)
 }
 
 func (c *HttpClient) PostJson(path string, payload any, result any, expectedStatus int) error {
+	return c.PostJsonWithContext(context.Background(), path, payload, result, expectedStatus)
+}
+
+func (c *HttpClient) PostJsonWithContext(ctx context.Context, path string, payload any, result any, expectedStatus int) error {
 	fullURL, err := c.makeURL(path, nil)
 	if err != nil {
 		return err
@@ -93,7 +164,7 @@ func (c *HttpClient) PostJson(path string, payload any, result any, expectedStat
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, fullURL, bytes.NewBuffer(payloadBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fullURL, bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return err
 	}
@@ -111,11 +182,11 @@ func (c *HttpClient) PostJson(path string, payload any, result any, expectedStat
 
 	if resp.StatusCode != expectedStatus {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("error: %s", string(bodyBytes))
+		return &HttpError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
 	if result == nil {
 		return nil
 	}
-	return json.NewDecoder(resp.Body).Decode(&result)
+	return decodeResponse(resp.Body, result)
 }
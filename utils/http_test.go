@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestIsUnsupportedEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "method not allowed", err: &HttpError{StatusCode: http.StatusMethodNotAllowed}, want: true},
+		{name: "not implemented", err: &HttpError{StatusCode: http.StatusNotImplemented}, want: true},
+		{
+			name: "unknown route",
+			err:  &HttpError{StatusCode: http.StatusNotFound, Body: `{"message":"Cannot POST /quotas/consumeUsage","error":"Not Found","statusCode":404}`},
+			want: true,
+		},
+		{
+			name: "unknown user",
+			err:  &HttpError{StatusCode: http.StatusNotFound, Body: `{"message":"User not found","error":"Not Found","statusCode":404}`},
+			want: false,
+		},
+		{name: "empty not found", err: &HttpError{StatusCode: http.StatusNotFound}, want: false},
+		{name: "bad request", err: &HttpError{StatusCode: http.StatusBadRequest}, want: false},
+		{name: "other error", err: errors.New("connection refused"), want: false},
+		{name: "no error", err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUnsupportedEndpoint(tt.err); got != tt.want {
+				t.Errorf("IsUnsupportedEndpoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// KeyValueStore is a minimal byte-oriented key/value store.
+//
+// It is meant to be implemented on top of a shared store such as Redis or memcached,
+// so that several instances of your service share the same cache through a KeyValueCache.
+//
+//   - Get returns nil (and no error) when the key is missing or expired.
+//   - Set stores value under key for the given ttl. A ttl of zero means no expiration.
+//   - Delete removes the value stored under key, if any.
+type KeyValueStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// KeyValueCache is a Cache that stores JSON encoded values in a KeyValueStore.
+type KeyValueCache[T any] struct {
+	store      KeyValueStore
+	prefix     string
+	defaultTtl time.Duration
+}
+
+// NewKeyValueCache creates a new KeyValueCache.
+//
+//   - @param store - The store holding the values.
+//   - @param prefix - The prefix prepended to every key, to share a store between several caches.
+//   - @param defaultTtl - The ttl applied when Set is called with a ttl of zero. Zero means no expiration.
+func NewKeyValueCache[T any](store KeyValueStore, prefix string, defaultTtl time.Duration) *KeyValueCache[T] {
+	return &KeyValueCache[T]{
+		store:      store,
+		prefix:     prefix,
+		defaultTtl: defaultTtl,
+	}
+}
+
+func (c *KeyValueCache[T]) Get(ctx context.Context, key string) (*T, error) {
+	raw, err := c.store.Get(ctx, c.prefix+key)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	var data T
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (c *KeyValueCache[T]) Set(ctx context.Context, key string, data T, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.defaultTtl
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.store.Set(ctx, c.prefix+key, raw, ttl)
+}
+
+func (c *KeyValueCache[T]) Delete(ctx context.Context, key string) error {
+	return c.store.Delete(ctx, c.prefix+key)
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapStore is an in-process KeyValueStore that records the ttl of every entry.
+type mapStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	ttls   map[string]time.Duration
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+}
+
+func (s *mapStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key], nil
+}
+
+func (s *mapStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.ttls[key] = ttl
+	return nil
+}
+
+func (s *mapStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	delete(s.ttls, key)
+	return nil
+}
+
+type kvTestValue struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestKeyValueCache(t *testing.T) {
+	ctx := context.Background()
+	value := kvTestValue{Name: "a", Count: 1}
+
+	tests := []struct {
+		name       string
+		defaultTtl time.Duration
+		ttl        time.Duration
+		wantTtl    time.Duration
+	}{
+		{name: "explicit ttl", defaultTtl: time.Minute, ttl: time.Second, wantTtl: time.Second},
+		{name: "default ttl", defaultTtl: time.Minute, wantTtl: time.Minute},
+		{name: "no ttl", wantTtl: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMapStore()
+			cache := NewKeyValueCache[kvTestValue](store, "test:", tt.defaultTtl)
+
+			got, err := cache.Get(ctx, "key")
+			if err != nil || got != nil {
+				t.Fatalf("Get() on a missing key = %v, %v, want nil, nil", got, err)
+			}
+
+			if err := cache.Set(ctx, "key", value, tt.ttl); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if _, ok := store.values["test:key"]; !ok {
+				t.Fatalf("store keys = %v, want the prefixed key test:key", store.values)
+			}
+			if got := store.ttls["test:key"]; got != tt.wantTtl {
+				t.Errorf("stored ttl = %v, want %v", got, tt.wantTtl)
+			}
+
+			got, err = cache.Get(ctx, "key")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got == nil || *got != value {
+				t.Errorf("Get() = %v, want %v", got, value)
+			}
+
+			if err := cache.Delete(ctx, "key"); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, ok := store.values["test:key"]; ok {
+				t.Errorf("Delete() left test:key in the store")
+			}
+			got, err = cache.Get(ctx, "key")
+			if err != nil || got != nil {
+				t.Errorf("Get() after Delete() = %v, %v, want nil, nil", got, err)
+			}
+		})
+	}
+}
+
+func TestKeyValueCacheSharedStore(t *testing.T) {
+	ctx := context.Background()
+	store := newMapStore()
+	first := NewKeyValueCache[string](store, "first:", 0)
+	second := NewKeyValueCache[string](store, "second:", 0)
+
+	_ = first.Set(ctx, "key", "one", 0)
+	_ = second.Set(ctx, "key", "two", 0)
+
+	if got, _ := first.Get(ctx, "key"); got == nil || *got != "one" {
+		t.Errorf("first.Get() = %v, want one", got)
+	}
+	if got, _ := second.Get(ctx, "key"); got == nil || *got != "two" {
+		t.Errorf("second.Get() = %v, want two", got)
+	}
+}
+
+func TestKeyValueCacheDecodeFailure(t *testing.T) {
+	ctx := context.Background()
+	store := newMapStore()
+	cache := NewKeyValueCache[kvTestValue](store, "test:", 0)
+
+	_ = store.Set(ctx, "test:key", []byte("not json"), 0)
+
+	got, err := cache.Get(ctx, "key")
+	if err == nil {
+		t.Fatalf("Get() = %v, want a decode error", got)
+	}
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) || got != nil {
+		t.Errorf("Get() = %v, %v, want nil and a *json.SyntaxError", got, err)
+	}
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// LoadingCacheConfig is the configuration of a LoadingCache.
+//
+//   - Ttl is how long a loaded value is considered fresh.
+//   - StaleTtl is how long a value keeps being served once stale, while it is refreshed in the background. Zero disables stale-while-revalidate.
+//   - LoadTimeout bounds each load, which is shared by every caller waiting for the same key. Defaults to 30 seconds.
+type LoadingCacheConfig struct {
+	Ttl         time.Duration
+	StaleTtl    time.Duration
+	LoadTimeout time.Duration
+}
+
+const (
+	defaultLoadTimeout        = 30 * time.Second
+	loadingCacheMaxFreshTimes = 10000
+)
+
+// LoadingCache is a read-through cache on top of a Cache.
+//
+// Concurrent misses for the same key are coalesced into a single load.
+// Stale values are served while a single background load refreshes them.
+//
+// Values are stored as is in the underlying Cache, for Ttl + StaleTtl. When StaleTtl is set, the time at which
+// each value becomes stale is tracked in process: values loaded by another process sharing the Cache are
+// considered stale, and refreshed in the background on their first read.
+type LoadingCache[T any] struct {
+	cache  Cache[T]
+	config LoadingCacheConfig
+	group  flightGroup[T]
+	fresh  *MemoryCache[struct{}]
+}
+
+// NewLoadingCache creates a new LoadingCache storing its values in cache.
+func NewLoadingCache[T any](cache Cache[T], config LoadingCacheConfig) *LoadingCache[T] {
+	if config.LoadTimeout <= 0 {
+		config.LoadTimeout = defaultLoadTimeout
+	}
+
+	c := &LoadingCache[T]{
+		cache:  cache,
+		config: config,
+	}
+	if config.StaleTtl > 0 {
+		c.fresh = NewMemoryCache[struct{}](CacheConfig{MaxEntries: loadingCacheMaxFreshTimes})
+	}
+	return c
+}
+
+// Get returns the value cached under key, calling load to fetch it when missing.
+//
+// When the cached value is stale but still within its StaleTtl, it is returned immediately
+// and load is called in the background to refresh it.
+// A failing cache backend is treated as a cache miss.
+//
+// The load is shared by every caller waiting for key, so it does not inherit the cancellation of ctx:
+// a canceled caller returns ctx.Err() without failing the others.
+func (c *LoadingCache[T]) Get(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	cached, err := c.cache.Get(ctx, key)
+	if err == nil && cached != nil {
+		if c.isFresh(ctx, key) {
+			return *cached, nil
+		}
+
+		if !c.group.inFlight(key) {
+			refreshCtx := context.WithoutCancel(ctx)
+			go func() {
+				_, _ = c.load(refreshCtx, key, load)
+			}()
+		}
+		return *cached, nil
+	}
+
+	return c.load(ctx, key, load)
+}
+
+func (c *LoadingCache[T]) isFresh(ctx context.Context, key string) bool {
+	if c.fresh == nil {
+		return true
+	}
+
+	fresh, _ := c.fresh.Get(ctx, key)
+	return fresh != nil
+}
+
+func (c *LoadingCache[T]) load(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	return c.group.do(ctx, key, func() (T, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.config.LoadTimeout)
+		defer cancel()
+
+		data, err := load(loadCtx)
+		if err != nil {
+			return data, err
+		}
+
+		_ = c.Set(loadCtx, key, data)
+		return data, nil
+	})
+}
+
+// Peek returns the value cached under key, fresh or stale, without loading it. It returns nil when there is none.
+func (c *LoadingCache[T]) Peek(ctx context.Context, key string) (*T, error) {
+	return c.cache.Get(ctx, key)
+}
+
+// Set stores data under key as a freshly loaded value.
+func (c *LoadingCache[T]) Set(ctx context.Context, key string, data T) error {
+	if c.fresh != nil {
+		_ = c.fresh.Set(ctx, key, struct{}{}, c.config.Ttl)
+	}
+	return c.cache.Set(ctx, key, data, c.config.Ttl+c.config.StaleTtl)
+}
+
+// Clear removes every value when the underlying Cache supports it, as MemoryCache does, and reports whether it did.
+// Otherwise, the values are kept until they expire. When StaleTtl is set, they are still refreshed in the background on their next read.
+func (c *LoadingCache[T]) Clear() bool {
+	if c.fresh != nil {
+		c.fresh.Clear()
+	}
+
+	clearer, ok := c.cache.(interface{ Clear() })
+	if !ok {
+		return false
+	}
+	clearer.Clear()
+	return true
+}
+
+// Delete removes the value stored under key, if any.
+func (c *LoadingCache[T]) Delete(ctx context.Context, key string) error {
+	if c.fresh != nil {
+		_ = c.fresh.Delete(ctx, key)
+	}
+	return c.cache.Delete(ctx, key)
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+type flightCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// flightGroup coalesces concurrent calls sharing the same key into a single execution.
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+// do executes fn for key, unless a call for the same key is already in flight,
+// in which case it waits for that call and returns its result.
+//
+// fn runs in its own goroutine and is never canceled by ctx: each caller only stops waiting
+// for the shared result when its own ctx is done, leaving the other callers unaffected.
+func (g *flightGroup[T]) do(ctx context.Context, key string, fn func() (T, error)) (T, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	c, ok := g.calls[key]
+	if !ok {
+		c = &flightCall[T]{done: make(chan struct{})}
+		g.calls[key] = c
+		go func() {
+			c.val, c.err = fn()
+
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(c.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// inFlight reports whether a call for key is currently being executed.
+func (g *flightGroup[T]) inFlight(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, ok := g.calls[key]
+	return ok
+}
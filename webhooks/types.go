@@ -1,5 +1,7 @@
 package webhooks
 
+import "github.com/kobble-io/go-admin/common"
+
 var WebhookSubscriptions = []string{
 	"user.created",
 	"quota.reached",
@@ -17,19 +19,20 @@ type WebhookSubscriptionData struct {
 		ProductID      string `json:"product_id"`
 		PriceID        string `json:"price_id"`
 	} `json:"provider"`
-	ProjectID         string  `json:"project_id"`
-	ProductID         string  `json:"product_id"`
-	PriceID           string  `json:"price_id"`
-	UserID            string  `json:"user_id"`
-	Email             string  `json:"email"`
-	StartDate         *string `json:"start_date,omitempty"`
-	EndedAt           *string `json:"ended_at,omitempty"`
-	CancelAt          *string `json:"cancel_at,omitempty"`
-	CanceledAt        *string `json:"canceled_at,omitempty"`
-	CancelAtPeriodEnd bool    `json:"cancel_at_period_end"`
-	Status            string  `json:"status"`
-	TrialEnd          *string `json:"trial_end,omitempty"`
-	TrialStart        *string `json:"trial_start,omitempty"`
+	ProjectID         string          `json:"project_id"`
+	ProductID         string          `json:"product_id"`
+	Product           *common.Product `json:"product,omitempty"`
+	PriceID           string          `json:"price_id"`
+	UserID            string          `json:"user_id"`
+	Email             string          `json:"email"`
+	StartDate         *string         `json:"start_date,omitempty"`
+	EndedAt           *string         `json:"ended_at,omitempty"`
+	CancelAt          *string         `json:"cancel_at,omitempty"`
+	CanceledAt        *string         `json:"canceled_at,omitempty"`
+	CancelAtPeriodEnd bool            `json:"cancel_at_period_end"`
+	Status            string          `json:"status"`
+	TrialEnd          *string         `json:"trial_end,omitempty"`
+	TrialStart        *string         `json:"trial_start,omitempty"`
 }
 
 type WebhookUserCreatedData struct {